@@ -1,27 +1,56 @@
 package config
 
 import (
-	"errors"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-var (
-	ErrNotFound       = errors.New("item not found")
-	ErrInvalidItem    = errors.New("invalid item")
-	ErrInternalServer = errors.New("internal server error")
-)
-
 type AppConfig struct {
 	Env                string
 	Port               string
+	Storage            string
 	UsersTable         string
 	ProjectTable       string
+	TokenTable         string
+	DatabaseURL        string
+	SQLitePath         string
 	AWSDefaultRegion   string
 	AWSAccessKeyID     string
 	AWSAccessSecretKey string
+	DynamoDBEndpoint   string
+	CreateTables       bool
+	RequestTimeout     time.Duration
+	AdminEmail         string
+	SecretKey          string
+	JWTAlgorithm       string
+	JWTPrivateKey      string
+	JWTRetiredKeys     []string
+	JWTKeyOverlap      time.Duration
+	JWTRotation        time.Duration
+	RequireVerified    string
+	LoginAttemptStore  string
+	TrustedProxies     []string
+	OAuthCallbackURL   string
+	GitHubClientID     string
+	GitHubClientSecret string
+	GoogleClientID     string
+	GoogleClientSecret string
+	OIDCIssuer         string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	AppURL             string
+	SecureCookies      bool
+	Mailer             string
+	MailFrom           string
+	MailLogPath        string
+	SMTPHost           string
+	SMTPPort           string
+	SMTPUsername       string
+	SMTPPassword       string
 	Testing            bool
 }
 
@@ -29,11 +58,45 @@ func NewConfiguration(Env string) *AppConfig {
 
 	var (
 		serverPort         = os.Getenv("SERVER_PORT")
+		storage            = os.Getenv("STORAGE")
 		AWSDefaultRegion   = os.Getenv("AWS_DEFAULT_REGION")
 		AWSAccessKeyID     = os.Getenv("AWS_ACCESS_KEY_ID")
 		AWSAccessSecretKey = os.Getenv("AWS_ACCESS_SECRET_KEY")
+		dynamoDBEndpoint   = os.Getenv("DYNAMODB_ENDPOINT")
+		createTables       = os.Getenv("DYNAMODB_CREATE_TABLES") == "true"
 		userTablename      = os.Getenv("USER_TABLE")
 		projectTablename   = os.Getenv("PROJECT_TABLE")
+		tokenTablename     = os.Getenv("TOKEN_TABLE")
+		databaseURL        = os.Getenv("DATABASE_URL")
+		sqlitePath         = os.Getenv("SQLITE_PATH")
+		requestTimeout     = 10 * time.Second
+		adminEmail         = os.Getenv("ADMIN_EMAIL")
+		secretKey          = os.Getenv("SECRET_KEY")
+		jwtAlgorithm       = os.Getenv("JWT_ALGORITHM")
+		jwtPrivateKey      = os.Getenv("JWT_PRIVATE_KEY")
+		jwtRetiredKeys     []string
+		jwtKeyOverlap      = time.Hour
+		jwtRotation        time.Duration
+		requireVerified    = os.Getenv("REQUIRE_VERIFIED_EMAIL")
+		loginAttemptStore  = os.Getenv("LOGIN_ATTEMPT_STORE")
+		trustedProxies     []string
+		oauthCallbackURL   = os.Getenv("OAUTH_CALLBACK_URL")
+		githubClientID     = os.Getenv("GITHUB_CLIENT_ID")
+		githubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
+		googleClientID     = os.Getenv("GOOGLE_CLIENT_ID")
+		googleClientSecret = os.Getenv("GOOGLE_CLIENT_SECRET")
+		oidcIssuer         = os.Getenv("OIDC_ISSUER")
+		oidcClientID       = os.Getenv("OIDC_CLIENT_ID")
+		oidcClientSecret   = os.Getenv("OIDC_CLIENT_SECRET")
+		appURL             = os.Getenv("APP_URL")
+		secureCookies      = os.Getenv("SECURE_COOKIES")
+		mailer             = os.Getenv("MAILER")
+		mailFrom           = os.Getenv("MAIL_FROM")
+		mailLogPath        = os.Getenv("MAIL_LOG_PATH")
+		smtpHost           = os.Getenv("SMTP_HOST")
+		smtpPort           = os.Getenv("SMTP_PORT")
+		smtpUsername       = os.Getenv("SMTP_USERNAME")
+		smtpPassword       = os.Getenv("SMTP_PASSWORD")
 		testing            = false
 	)
 
@@ -49,16 +112,140 @@ func NewConfiguration(Env string) *AppConfig {
 		testing = true
 		userTablename = os.Getenv("DEV_USER_TABLE")
 		projectTablename = os.Getenv("DEV_PROJECT_TABLE")
+		tokenTablename = os.Getenv("DEV_TOKEN_TABLE")
+		databaseURL = os.Getenv("DEV_DATABASE_URL")
+
+	}
 
+	if storage == "" {
+		storage = "dynamodb"
+	}
+	if sqlitePath == "" {
+		sqlitePath = "portfolio.db"
+	}
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	// Session cookies only travel over HTTPS when the app is served over it
+	secure := strings.HasPrefix(appURL, "https://")
+	switch secureCookies {
+	case "":
+	case "true":
+		secure = true
+	case "false":
+		secure = false
+	default:
+		log.Fatalf("Invalid SECURE_COOKIES %q, want true or false", secureCookies)
+	}
+	// Identity providers redirect back to <url>/<provider>/callback
+	if oauthCallbackURL == "" {
+		oauthCallbackURL = "http://localhost:" + serverPort + "/api/v1/login"
+	}
+	if oidcClientID != "" && oidcIssuer == "" {
+		log.Fatal("OIDC_ISSUER is required with OIDC_CLIENT_ID")
+	}
+	if mailer == "" {
+		mailer = "log"
+	}
+	if loginAttemptStore == "" {
+		loginAttemptStore = "repository"
 	}
+	// Client addresses are only read from X-Forwarded-For set by these
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+	if value := os.Getenv("REQUEST_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			log.Fatalf("Invalid REQUEST_TIMEOUT %q, want a duration such as 10s", value)
+		}
+		requestTimeout = timeout
+	}
+	if jwtAlgorithm == "" {
+		jwtAlgorithm = "HS256"
+	}
+	switch jwtAlgorithm {
+	case "HS256", "RS256", "EdDSA":
+	default:
+		log.Fatalf("Invalid JWT_ALGORITHM %q, want HS256, RS256 or EdDSA", jwtAlgorithm)
+	}
+	for _, path := range strings.Split(os.Getenv("JWT_RETIRED_KEYS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			jwtRetiredKeys = append(jwtRetiredKeys, path)
+		}
+	}
+	if value := os.Getenv("JWT_KEY_OVERLAP"); value != "" {
+		overlap, err := time.ParseDuration(value)
+		if err != nil || overlap <= 0 {
+			log.Fatalf("Invalid JWT_KEY_OVERLAP %q, want a duration such as 1h", value)
+		}
+		jwtKeyOverlap = overlap
+	}
+	// Keys are only rotated when asked to, zero keeps the configured key
+	if value := os.Getenv("JWT_ROTATION_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 0 {
+			log.Fatalf("Invalid JWT_ROTATION_INTERVAL %q, want a duration such as 24h", value)
+		}
+		jwtRotation = interval
+	}
+	switch requireVerified {
+	case "", "projects", "login":
+	default:
+		log.Fatalf("Invalid REQUIRE_VERIFIED_EMAIL %q, want projects or login", requireVerified)
+	}
+	// Anyone could sign verification links and login challenges without it
+	if secretKey == "" {
+		log.Fatal("SECRET_KEY is required, set it to a long random string such as the output of openssl rand -base64 32")
+	}
+
 	return &AppConfig{
 		Env:                Env,
 		Port:               serverPort,
+		Storage:            storage,
 		UsersTable:         userTablename,
 		ProjectTable:       projectTablename,
+		TokenTable:         tokenTablename,
+		DatabaseURL:        databaseURL,
+		SQLitePath:         sqlitePath,
 		AWSDefaultRegion:   AWSDefaultRegion,
 		AWSAccessKeyID:     AWSAccessKeyID,
 		AWSAccessSecretKey: AWSAccessSecretKey,
+		DynamoDBEndpoint:   dynamoDBEndpoint,
+		CreateTables:       createTables,
+		RequestTimeout:     requestTimeout,
+		AdminEmail:         adminEmail,
+		SecretKey:          secretKey,
+		JWTAlgorithm:       jwtAlgorithm,
+		JWTPrivateKey:      jwtPrivateKey,
+		JWTRetiredKeys:     jwtRetiredKeys,
+		JWTKeyOverlap:      jwtKeyOverlap,
+		JWTRotation:        jwtRotation,
+		RequireVerified:    requireVerified,
+		LoginAttemptStore:  loginAttemptStore,
+		TrustedProxies:     trustedProxies,
+		OAuthCallbackURL:   oauthCallbackURL,
+		GitHubClientID:     githubClientID,
+		GitHubClientSecret: githubClientSecret,
+		GoogleClientID:     googleClientID,
+		GoogleClientSecret: googleClientSecret,
+		OIDCIssuer:         oidcIssuer,
+		OIDCClientID:       oidcClientID,
+		OIDCClientSecret:   oidcClientSecret,
+		AppURL:             appURL,
+		SecureCookies:      secure,
+		Mailer:             mailer,
+		MailFrom:           mailFrom,
+		MailLogPath:        mailLogPath,
+		SMTPHost:           smtpHost,
+		SMTPPort:           smtpPort,
+		SMTPUsername:       smtpUsername,
+		SMTPPassword:       smtpPassword,
 		Testing:            testing,
 	}
 }
@@ -9,7 +9,15 @@ Description :
 package gin
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/AntonyIS/portfolio-be/internal/adapters/middleware"
 	"github.com/AntonyIS/portfolio-be/internal/core/domain"
@@ -18,13 +26,33 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var revokedTokens []string
-
 type GinHandler interface {
 	PostUser(ctx *gin.Context)
 	GetUser(ctx *gin.Context)
 	GetUsers(ctx *gin.Context)
 	PutUser(ctx *gin.Context)
+	PutUserRole(ctx *gin.Context)
+	PostTOTP(ctx *gin.Context)
+	ConfirmTOTP(ctx *gin.Context)
+	DeleteTOTP(ctx *gin.Context)
+	PostCertification(ctx *gin.Context)
+	GetCertification(ctx *gin.Context)
+	GetCertifications(ctx *gin.Context)
+	PutCertification(ctx *gin.Context)
+	DeleteCertification(ctx *gin.Context)
+	PostExperience(ctx *gin.Context)
+	GetExperience(ctx *gin.Context)
+	GetExperiences(ctx *gin.Context)
+	PutExperience(ctx *gin.Context)
+	DeleteExperience(ctx *gin.Context)
+	PostEducation(ctx *gin.Context)
+	GetEducation(ctx *gin.Context)
+	GetEducations(ctx *gin.Context)
+	PutEducation(ctx *gin.Context)
+	DeleteEducation(ctx *gin.Context)
+	PostAPIKey(ctx *gin.Context)
+	GetAPIKeys(ctx *gin.Context)
+	DeleteAPIKey(ctx *gin.Context)
 	DeleteUser(ctx *gin.Context)
 	PostProject(ctx *gin.Context)
 	GetProject(ctx *gin.Context)
@@ -33,119 +61,506 @@ type GinHandler interface {
 	DeleteProject(ctx *gin.Context)
 	Home(ctx *gin.Context)
 	Login(ctx *gin.Context)
+	LoginTwoFactor(ctx *gin.Context)
+	LoginWithProvider(ctx *gin.Context)
+	LoginCallback(ctx *gin.Context)
 	Logout(ctx *gin.Context)
+	RefreshToken(ctx *gin.Context)
+	ForgotPassword(ctx *gin.Context)
+	ResetPassword(ctx *gin.Context)
+	VerifyEmail(ctx *gin.Context)
+	ResendVerification(ctx *gin.Context)
 	Signup(ctx *gin.Context)
 }
 
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+	// refreshTokenPath limits the refresh token cookie to the requests that
+	// use it
+	refreshTokenPath = "/api/v1"
+	// loginStatePath limits the cookie with the state of a login at an
+	// identity provider to the login requests
+	loginStatePath = "/api/v1/login"
+	// loginStateTTL is how long users have to log in at the provider
+	loginStateTTL = 10 * time.Minute
+)
+
 type handler struct {
-	svc services.PortfolioService
+	svc  services.PortfolioService
+	keys *middleware.KeyManager
+	// secureCookies keeps the session and login cookies off plain HTTP
+	secureCookies bool
 }
 
-func NewGinHandler(svc services.PortfolioService) GinHandler {
+func NewGinHandler(svc services.PortfolioService, keys *middleware.KeyManager, secureCookies bool) GinHandler {
 	return handler{
-		svc: svc,
+		svc:           svc,
+		keys:          keys,
+		secureCookies: secureCookies,
 	}
 }
 
 func (h handler) PostUser(ctx *gin.Context) {
-	var user domain.User
-	if err := ctx.ShouldBindJSON(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	var body SignupRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
 		return
 	}
-	res, err := h.svc.CreateUser(&user)
+	res, err := h.svc.CreateUser(ctx.Request.Context(), body.user())
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
 		return
 	}
+	h.sendVerificationEmail(ctx, res)
 
-	ctx.JSON(http.StatusCreated, res)
+	ctx.JSON(http.StatusCreated, newUserResponse(res))
 }
 
 func (h handler) GetUser(ctx *gin.Context) {
 	id := ctx.Param("id")
-	user, err := h.svc.ReadUser(id)
+	user, err := h.svc.ReadUser(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
 
+func (h handler) GetUsers(ctx *gin.Context) {
+	limit, cursor, err := pageParams(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"err": err.Error(),
-		})
+		ctx.Error(err)
 		return
 	}
-	if user == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"message": "user not found",
-		})
+	users, next, err := h.svc.ReadUsers(ctx.Request.Context(), limit, cursor)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, user)
-	return
+	ctx.JSON(http.StatusOK, gin.H{
+		"users":       newUserResponses(users),
+		"next_cursor": next,
+	})
 }
 
-func (h handler) GetUsers(ctx *gin.Context) {
-	users, err := h.svc.ReadUsers()
+func (h handler) PutUser(ctx *gin.Context) {
+	if err := middleware.Allow(ctx, middleware.UpdateUser, ctx.Param("id")); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body UpdateUserRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	res, err := h.svc.UpdateUser(ctx.Request.Context(), body.user(ctx.Param("id")))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, users)
+
+	ctx.JSON(http.StatusOK, newUserResponse(res))
 }
 
-func (h handler) PutUser(ctx *gin.Context) {
-	var user domain.User
-	if err := ctx.ShouldBindJSON(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+func (h handler) PutUserRole(ctx *gin.Context) {
+	if err := middleware.Allow(ctx, middleware.ManageRoles, ctx.Param("id")); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body struct {
+		Role domain.Role `json:"role" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
 		return
 	}
-	res, err := h.svc.UpdateUser(&user)
+	res, err := h.svc.SetUserRole(ctx.Request.Context(), ctx.Param("id"), body.Role)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(res))
+}
+
+func (h handler) PostTOTP(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	secret, uri, err := h.svc.EnrollTOTP(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"secret":     secret,
+		"otpauthUri": uri,
+	})
+}
+
+func (h handler) ConfirmTOTP(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	codes, err := h.svc.ConfirmTOTP(ctx.Request.Context(), id, body.Code)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	// The recovery codes are only shown this once
+	ctx.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": codes,
+	})
+}
+
+func (h handler) DeleteTOTP(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
 		return
 	}
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	if err := h.svc.DisableTOTP(ctx.Request.Context(), id, body.Code); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication turned off",
+	})
+}
 
+func (h handler) PostCertification(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body CertificationRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	res, err := h.svc.CreateCertification(ctx.Request.Context(), body.certification(id, ""))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 	ctx.JSON(http.StatusCreated, res)
-	return
 }
 
-func (h handler) DeleteUser(ctx *gin.Context) {
+func (h handler) GetCertification(ctx *gin.Context) {
+	certification, err := h.svc.ReadCertification(ctx.Request.Context(), ctx.Param("id"), ctx.Param("certification"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, certification)
+}
+
+func (h handler) GetCertifications(ctx *gin.Context) {
+	certifications, err := h.svc.ReadCertifications(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"certifications": certifications,
+	})
+}
+
+func (h handler) PutCertification(ctx *gin.Context) {
 	id := ctx.Param("id")
-	err := h.svc.DeleteUser(id)
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body CertificationRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	// Certifications can't be handed over to another user
+	res, err := h.svc.UpdateCertification(ctx.Request.Context(), body.certification(id, ctx.Param("certification")))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (h handler) DeleteCertification(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := h.svc.DeleteCertification(ctx.Request.Context(), id, ctx.Param("certification")); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Certification deleted successfully",
+	})
+}
+
+func (h handler) PostExperience(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body ExperienceRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	res, err := h.svc.CreateExperience(ctx.Request.Context(), body.experience(id, ""))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, res)
+}
+
+func (h handler) GetExperience(ctx *gin.Context) {
+	experience, err := h.svc.ReadExperience(ctx.Request.Context(), ctx.Param("id"), ctx.Param("experience"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, experience)
+}
+
+// GetExperiences responds with the work experience of a user, most recent first.
+func (h handler) GetExperiences(ctx *gin.Context) {
+	experiences, err := h.svc.ReadExperiences(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"experiences": experiences,
+	})
+}
+
+func (h handler) PutExperience(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body ExperienceRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	// Experience can't be handed over to another user
+	res, err := h.svc.UpdateExperience(ctx.Request.Context(), body.experience(id, ctx.Param("experience")))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (h handler) DeleteExperience(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := h.svc.DeleteExperience(ctx.Request.Context(), id, ctx.Param("experience")); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Experience deleted successfully",
+	})
+}
+
+func (h handler) PostEducation(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body EducationRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	res, err := h.svc.CreateEducation(ctx.Request.Context(), body.education(id, ""))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, res)
+}
+
+func (h handler) GetEducation(ctx *gin.Context) {
+	education, err := h.svc.ReadEducation(ctx.Request.Context(), ctx.Param("id"), ctx.Param("education"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, education)
+}
+
+// GetEducations responds with the education of a user, most recent first.
+func (h handler) GetEducations(ctx *gin.Context) {
+	education, err := h.svc.ReadEducations(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"education": education,
+	})
+}
+
+func (h handler) PutEducation(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body EducationRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	// Education can't be handed over to another user
+	res, err := h.svc.UpdateEducation(ctx.Request.Context(), body.education(id, ctx.Param("education")))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (h handler) DeleteEducation(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.UpdateUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := h.svc.DeleteEducation(ctx.Request.Context(), id, ctx.Param("education")); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Education deleted successfully",
+	})
+}
+
+// PostAPIKey responds with a new API key, the only time the key is shown.
+func (h handler) PostAPIKey(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.ManageAPIKeys, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	var body CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	key, apiKey, err := h.svc.CreateAPIKey(ctx.Request.Context(), id, body.Name, body.Scopes, body.ExpiresAt)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, gin.H{
+		"key":     key,
+		"api_key": newAPIKeyResponse(apiKey),
+	})
+}
+
+func (h handler) GetAPIKeys(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.ManageAPIKeys, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	keys, err := h.svc.ReadAPIKeys(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"api_keys": newAPIKeyResponses(keys),
+	})
+}
+
+func (h handler) DeleteAPIKey(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.ManageAPIKeys, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := h.svc.RevokeAPIKey(ctx.Request.Context(), id, ctx.Param("key")); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked",
+	})
+}
+
+func (h handler) DeleteUser(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := middleware.Allow(ctx, middleware.DeleteUser, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	err := h.svc.DeleteUser(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
 		"message": "User deleted successfully",
 	})
 }
 
 func (h handler) PostProject(ctx *gin.Context) {
-	var project domain.Project
-	if err := ctx.ShouldBindJSON(&project); err != nil {
-
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	var body CreateProjectRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	project := body.project()
+	// Projects are created for the authenticated user unless the request
+	// names its owner
+	if project.UserID == "" {
+		project.UserID = ctx.GetString("user_id")
+	}
+	if err := middleware.Allow(ctx, middleware.WriteProjects, project.UserID); err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	res, err := h.svc.CreateProject(&project)
+	res, err := h.svc.CreateProject(ctx.Request.Context(), project)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
 		return
 	}
 
@@ -154,65 +569,74 @@ func (h handler) PostProject(ctx *gin.Context) {
 
 func (h handler) GetProject(ctx *gin.Context) {
 	id := ctx.Param("id")
-	project, err := h.svc.ReadProject(id)
+	project, err := h.svc.ReadProject(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"err": err.Error(),
-		})
-		return
-	}
-	if project == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"message": "project not found",
-		})
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, project)
-	return
 }
 
 func (h handler) GetProjects(ctx *gin.Context) {
-	projects, err := h.svc.ReadProjects()
+	limit, cursor, err := pageParams(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"err": err.Error(),
-		})
+		ctx.Error(err)
+		return
+	}
+	projects, next, err := h.svc.ReadProjects(ctx.Request.Context(), limit, cursor)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, projects)
-	return
+	ctx.JSON(http.StatusOK, gin.H{
+		"projects":    projects,
+		"next_cursor": next,
+	})
 }
 
 func (h handler) PutProject(ctx *gin.Context) {
-	var project domain.Project
-	if err := ctx.ShouldBindJSON(&project); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	var body UpdateProjectRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
 		return
 	}
-	res, err := h.svc.UpdateProject(&project)
+	existing, err := h.svc.ReadProject(ctx.Request.Context(), ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
+		return
+	}
+	if err := middleware.Allow(ctx, middleware.WriteProjects, existing.UserID); err != nil {
+		ctx.Error(err)
+		return
+	}
+	// Projects can't be handed over to another user, and who wrote them
+	// and when stays as it was
+	res, err := h.svc.UpdateProject(ctx.Request.Context(), body.project(existing))
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, res)
-	return
+	ctx.JSON(http.StatusOK, res)
 }
 
 func (h handler) DeleteProject(ctx *gin.Context) {
 	id := ctx.Param("id")
-	err := h.svc.DeleteProject(id)
+	project, err := h.svc.ReadProject(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusCreated, gin.H{
+	if err := middleware.Allow(ctx, middleware.DeleteProjects, project.UserID); err != nil {
+		ctx.Error(err)
+		return
+	}
+	err = h.svc.DeleteProject(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Project deleted successfully",
 	})
 }
@@ -224,86 +648,315 @@ func (h handler) Home(ctx *gin.Context) {
 }
 
 func (h handler) Login(ctx *gin.Context) {
-	var user domain.User
-	if err := ctx.ShouldBind(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
-		})
+	var body LoginRequest
+	if err := ctx.ShouldBind(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
 		return
 	}
-	dbUser, err := h.svc.ReadUserWithEmail(user.Email)
+	dbUser, err := h.svc.Authenticate(ctx.Request.Context(), body.Email, body.Password, ctx.ClientIP())
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
 		return
 	}
+	h.loggedIn(ctx, dbUser)
+}
 
-	if dbUser.CheckPasswordHarsh(user.Password) {
-		middleware := middleware.NewMiddleware(&h.svc)
-		tokenString, err := middleware.GenerateToken(dbUser.Id)
+// LoginWithProvider redirects to the identity provider in the path. The
+// state and the PKCE code verifier are kept in a cookie for LoginCallback.
+func (h handler) LoginWithProvider(ctx *gin.Context) {
+	state, err := randomString()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	verifier, err := randomString()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	redirect, err := h.svc.IdentityProviderURL(ctx.Param("provider"), state, verifier)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-		if err != nil {
-			ctx.JSON(http.StatusNotFound, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
+	// Lax, the provider redirects back with a top-level navigation
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie("login_state", state+"."+verifier, int(loginStateTTL.Seconds()), loginStatePath, "", h.secureCookies, true)
+	ctx.Redirect(http.StatusFound, redirect)
+}
 
-		ctx.SetSameSite(http.SameSiteLaxMode)
-		ctx.SetCookie("token", tokenString, 3600*24*30, "", "", false, true)
+// LoginCallback logs in the user the identity provider redirected back with
+// a code, if the state is the one LoginWithProvider set.
+func (h handler) LoginCallback(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+	if reason := ctx.Query("error"); reason != "" {
+		ctx.Error(domain.NewError(domain.ErrUnauthorized, "%s login failed: %s", provider, reason))
+		return
+	}
+	cookie, _ := ctx.Cookie("login_state")
+	state, verifier, _ := strings.Cut(cookie, ".")
+	// The state ties the callback to the browser that started the login
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(ctx.Query("state"))) != 1 {
+		ctx.Error(domain.NewError(domain.ErrValidation, "login state is invalid or expired, log in again"))
+		return
+	}
+	ctx.SetCookie("login_state", "", -1, loginStatePath, "", h.secureCookies, true)
+
+	user, err := h.svc.LoginWithIdentityProvider(ctx.Request.Context(), provider, ctx.Query("code"), verifier)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	h.loggedIn(ctx, user)
+}
 
+// loggedIn starts the session of a user who logged in, or asks for their
+// two-factor code first.
+func (h handler) loggedIn(ctx *gin.Context, user *domain.User) {
+	if user.TOTPEnabled {
+		// The session starts once LoginTwoFactor gets a code as well
 		ctx.JSON(http.StatusOK, gin.H{
-			"accessToken": tokenString,
+			"mfaRequired": true,
+			"mfaToken":    h.svc.LoginChallenge(user),
 		})
-
 		return
+	}
 
-	} else {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": "Invalid email or password",
-		})
+	refreshToken, err := h.svc.IssueRefreshToken(ctx.Request.Context(), user.Id)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
+	h.startSession(ctx, user.Id, refreshToken)
 }
 
-func (h handler) Logout(ctx *gin.Context) {
+func (h handler) LoginTwoFactor(ctx *gin.Context) {
+	var body struct {
+		MFAToken string `json:"mfaToken" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	user, err := h.svc.CompleteLogin(ctx.Request.Context(), body.MFAToken, body.Code, ctx.ClientIP())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	tokenString := ctx.GetHeader("tokenString")
+	refreshToken, err := h.svc.IssueRefreshToken(ctx.Request.Context(), user.Id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	h.startSession(ctx, user.Id, refreshToken)
+}
 
-	if tokenString == "" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"error": "Authorization header is missing",
-		})
+func (h handler) RefreshToken(ctx *gin.Context) {
+	refreshToken, fromCookie := refreshTokenParam(ctx)
+	if fromCookie {
+		if err := middleware.CheckCSRF(ctx); err != nil {
+			ctx.Error(err)
+			return
+		}
+	}
+	userID, refreshToken, err := h.svc.RotateRefreshToken(ctx.Request.Context(), refreshToken)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
+	h.startSession(ctx, userID, refreshToken)
+}
 
-	revokedTokens = append(revokedTokens, tokenString)
+func (h handler) Logout(ctx *gin.Context) {
+	if ctx.GetString("api_key") != "" {
+		ctx.Error(domain.NewError(domain.ErrValidation, "API keys are revoked, not logged out"))
+		return
+	}
+	// Authorize stored the id and expiry of the token
+	err := h.svc.RevokeToken(ctx.Request.Context(), ctx.GetString("jti"), ctx.GetTime("exp"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	if refreshToken, _ := refreshTokenParam(ctx); refreshToken != "" {
+		if err := h.svc.RevokeRefreshToken(ctx.Request.Context(), refreshToken); err != nil {
+			ctx.Error(err)
+			return
+		}
+	}
+	ctx.SetCookie("token", "", -1, "", "", h.secureCookies, true)
+	ctx.SetCookie("refresh_token", "", -1, refreshTokenPath, "", h.secureCookies, true)
+	ctx.SetCookie(middleware.CSRFCookie, "", -1, "", "", h.secureCookies, false)
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Token invalidated successfuly",
 	})
+}
 
+func (h handler) ForgotPassword(ctx *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	if err := h.svc.ForgotPassword(ctx.Request.Context(), body.Email); err != nil {
+		ctx.Error(err)
+		return
+	}
+	// The same response for every email, accounts can't be found this way
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "If an account uses this email, a password reset link is on its way",
+	})
+}
+
+func (h handler) ResetPassword(ctx *gin.Context) {
+	var body struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	if err := h.svc.ResetPassword(ctx.Request.Context(), body.Token, body.Password); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Password changed successfully",
+	})
 }
 
 func (h handler) Signup(ctx *gin.Context) {
 
-	var user domain.User
-	if err := ctx.ShouldBind(&user); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
-		})
+	var body SignupRequest
+	if err := ctx.ShouldBind(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
 		return
 	}
 
-	newUser, err := h.svc.CreateUser(&user)
+	newUser, err := h.svc.CreateUser(ctx.Request.Context(), body.user())
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		ctx.Error(err)
+		return
+	}
+	h.sendVerificationEmail(ctx, newUser)
+	ctx.JSON(http.StatusCreated, newUserResponse(newUser))
+}
+
+func (h handler) VerifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		ctx.Error(domain.NewError(domain.ErrValidation, "token is required"))
+		return
+	}
+	if _, err := h.svc.VerifyEmail(ctx.Request.Context(), token); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+	})
+}
+
+func (h handler) ResendVerification(ctx *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.Error(domain.NewError(domain.ErrValidation, err.Error()))
+		return
+	}
+	if err := h.svc.SendVerificationEmail(ctx.Request.Context(), body.Email); err != nil {
+		ctx.Error(err)
+		return
+	}
+	// The same response for every email, accounts can't be found this way
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "If an unverified account uses this email, a verification link is on its way",
+	})
+}
+
+// sendVerificationEmail mails the verification link to a new user. The
+// account exists already, so a mail that can't be sent is logged and the
+// user asks for another link later.
+func (h handler) sendVerificationEmail(ctx *gin.Context, user *domain.User) {
+	if err := h.svc.SendVerificationEmail(ctx.Request.Context(), user.Email); err != nil {
+		log.Printf("Sending verification email to user %s: %v", user.Id, err)
+	}
+}
+
+// randomString returns 32 random bytes, base64url encoded as PKCE wants the
+// code verifier.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// startSession responds with a new access token for the user and the
+// refresh token to exchange for the next one, both also set as cookies. The
+// CSRF token the frontend repeats in requests authenticated by those cookies
+// is set as a cookie it can read.
+func (h handler) startSession(ctx *gin.Context, userID, refreshToken string) {
+	tokenString, err := middleware.NewMiddleware(&h.svc, h.keys).GenerateToken(ctx.Request.Context(), userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		// The user was deleted after logging in
+		ctx.Error(domain.NewError(domain.ErrUnauthorized, "Invalid email or password"))
+		return
+	}
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	csrfToken, err := randomString()
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusCreated, newUser)
-	return
 
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie("token", tokenString, int(middleware.AccessTokenTTL.Seconds()), "", "", h.secureCookies, true)
+	ctx.SetCookie("refresh_token", refreshToken, int(services.RefreshTokenTTL.Seconds()), refreshTokenPath, "", h.secureCookies, true)
+	ctx.SetCookie(middleware.CSRFCookie, csrfToken, int(services.RefreshTokenTTL.Seconds()), "", "", h.secureCookies, false)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"accessToken":  tokenString,
+		"refreshToken": refreshToken,
+		"csrfToken":    csrfToken,
+	})
+}
+
+// refreshTokenParam reads the refresh token from the request body, or from
+// the cookie set by startSession and then reports fromCookie.
+func refreshTokenParam(ctx *gin.Context) (refreshToken string, fromCookie bool) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err == nil && body.RefreshToken != "" {
+		return body.RefreshToken, false
+	}
+	refreshToken, _ = ctx.Cookie("refresh_token")
+	return refreshToken, refreshToken != ""
+}
+
+// pageParams reads the limit and cursor query parameters of a listing. The
+// cursor is the next_cursor returned with the previous page.
+func pageParams(ctx *gin.Context) (int, string, error) {
+	limit := defaultPageLimit
+	if value := ctx.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return 0, "", domain.NewError(domain.ErrValidation, "limit must be a number between 1 and %d", maxPageLimit)
+		}
+		limit = n
+	}
+	return limit, ctx.Query("cursor"), nil
 }
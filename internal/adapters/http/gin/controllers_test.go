@@ -2,44 +2,62 @@ package gin
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/middleware"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/oauth"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/oauth/oauthtest"
 	"github.com/AntonyIS/portfolio-be/internal/adapters/repository"
 	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
 	"github.com/AntonyIS/portfolio-be/internal/core/services"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/assert"
+	"github.com/golang-jwt/jwt"
 )
 
-func init() {
-	config.LoadEnv("../../../../.env")
-}
 func SetUpRouter() *gin.Engine {
 	router := gin.Default()
+	router.Use(middleware.HandleErrors)
 	return router
 }
 
 func TestApplicationRoutes(t *testing.T) {
-	config := config.NewConfiguration("testing")
-	repo := repository.NewDynamoDBRepository(config)
-	svc := services.NewPortfolioService(&repo)
+	repo := repository.NewInMemoryRepository()
+	mailbox := &testMailer{}
+	options := services.Options{AppURL: "http://localhost:3000", SecretKey: []byte("test-secret")}
+	svc := services.NewPortfolioService(&repo, mailbox, options)
+	keys, err := middleware.NewHMACKeyManager([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	handler := NewGinHandler(*svc)
+	handler := NewGinHandler(*svc, keys, false)
 	t.Run("Gin Post user", func(t *testing.T) {
 		r := SetUpRouter()
 		r.POST("/api/v1/signup", handler.PostUser)
 
-		newUser := domain.User{
+		newUser := SignupRequest{
 			FirstName: "Marco",
 			LastName:  "Injila",
 			Email:     "marco@gmail.com",
 			Title:     "Golang Software Engineer",
 			Password:  "password",
-			Projects:  nil,
 		}
 		jsonValue, _ := json.Marshal(newUser)
 		req, _ := http.NewRequest("POST", "/api/v1/signup", bytes.NewBuffer(jsonValue))
@@ -47,7 +65,1086 @@ func TestApplicationRoutes(t *testing.T) {
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusCreated, w.Code)
+		if bytes.Contains(w.Body.Bytes(), []byte("password")) {
+			t.Errorf("created user response has a password: %s", w.Body.String())
+		}
+	})
+	t.Run("Gin Post user with taken email", func(t *testing.T) {
+		r := SetUpRouter()
+		r.POST("/api/v1/signup", handler.Signup)
+
+		jsonValue, _ := json.Marshal(SignupRequest{Email: "taken@gmail.com", Password: "password"})
+		req, _ := http.NewRequest("POST", "/api/v1/signup", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		req, _ = http.NewRequest("POST", "/api/v1/signup", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Gin Read user with non existing id", func(t *testing.T) {
+		r := SetUpRouter()
+		r.GET("/api/v1/users/:id", handler.GetUser)
+		req, _ := http.NewRequest("GET", "/api/v1/users/1234ewe", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+		var problem middleware.Problem
+		json.Unmarshal(w.Body.Bytes(), &problem)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+		assert.Equal(t, "/api/v1/users/1234ewe", problem.Instance)
+	})
+
+	t.Run("Gin Delete user", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.DELETE("/api/v1/users/:id", auth.Authorize, handler.DeleteUser)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "delete@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := auth.GenerateToken(context.Background(), user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("DELETE", "/api/v1/users/"+user.Id, nil)
+		req.Header.Set("token", token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req, _ = http.NewRequest("DELETE", "/api/v1/users/"+user.Id, nil)
+		req.Header.Set("token", token)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Gin Read users in pages", func(t *testing.T) {
+		r := SetUpRouter()
+		r.GET("/api/v1/users", handler.GetUsers)
+
+		for _, email := range []string{"page1@gmail.com", "page2@gmail.com", "page3@gmail.com"} {
+			_, err := svc.CreateUser(context.Background(), &domain.User{Email: email, Password: "password"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		seen := 0
+		url := "/api/v1/users?limit=2"
+		for url != "" {
+			req, _ := http.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			if bytes.Contains(w.Body.Bytes(), []byte("password")) {
+				t.Errorf("listed users with passwords: %s", w.Body.String())
+			}
+
+			var page struct {
+				Users      []UserResponse `json:"users"`
+				NextCursor string         `json:"next_cursor"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &page)
+			if len(page.Users) > 2 {
+				t.Errorf("page has %d users, want at most 2", len(page.Users))
+			}
+			seen += len(page.Users)
+
+			url = ""
+			if page.NextCursor != "" {
+				url = "/api/v1/users?limit=2&cursor=" + page.NextCursor
+			}
+		}
+		if seen < 3 {
+			t.Errorf("listed %d users, want at least 3", seen)
+		}
+	})
+
+	t.Run("Gin Read users with invalid limit", func(t *testing.T) {
+		r := SetUpRouter()
+		r.GET("/api/v1/users", handler.GetUsers)
+		req, _ := http.NewRequest("GET", "/api/v1/users?limit=0", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Gin request past its deadline", func(t *testing.T) {
+		r := SetUpRouter()
+		r.Use(middleware.Timeout(time.Millisecond))
+		r.GET("/api/v1/slow", func(ctx *gin.Context) {
+			// Stands in for a storage call that gives up on the deadline
+			<-ctx.Request.Context().Done()
+			ctx.Error(errors.New("request canceled"))
+		})
+		req, _ := http.NewRequest("GET", "/api/v1/slow", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	})
+
+	t.Run("Gin change users and projects of other users", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.PUT("/api/v1/users/:id", auth.Authorize, handler.PutUser)
+		r.DELETE("/api/v1/users/:id", auth.Authorize, handler.DeleteUser)
+		r.POST("/api/v1/projects", auth.Authorize, handler.PostProject)
+		r.DELETE("/api/v1/projects/:id", auth.Authorize, handler.DeleteProject)
+
+		owner, err := svc.CreateUser(context.Background(), &domain.User{Email: "owner@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := svc.CreateUser(context.Background(), &domain.User{Email: "other@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		project, err := svc.CreateProject(context.Background(), &domain.Project{UserID: owner.Id, Title: "Go gRPC for beginners"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ownerToken, _ := auth.GenerateToken(context.Background(), owner.Id)
+		otherToken, _ := auth.GenerateToken(context.Background(), other.Id)
+
+		send := func(method, url, token string, body interface{}) int {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("token", token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, send("PUT", "/api/v1/users/"+owner.Id, "", owner))
+		assert.Equal(t, http.StatusUnauthorized, send("PUT", "/api/v1/users/"+owner.Id, "not a token", owner))
+		assert.Equal(t, http.StatusForbidden, send("PUT", "/api/v1/users/"+owner.Id, otherToken, owner))
+		assert.Equal(t, http.StatusForbidden, send("DELETE", "/api/v1/users/"+owner.Id, otherToken, nil))
+		assert.Equal(t, http.StatusForbidden, send("POST", "/api/v1/projects", otherToken, domain.Project{UserID: owner.Id}))
+		assert.Equal(t, http.StatusForbidden, send("DELETE", "/api/v1/projects/"+project.Id, otherToken, nil))
+
+		assert.Equal(t, http.StatusOK, send("PUT", "/api/v1/users/"+owner.Id, ownerToken, owner))
+		assert.Equal(t, http.StatusOK, send("DELETE", "/api/v1/projects/"+project.Id, ownerToken, nil))
+		assert.Equal(t, http.StatusOK, send("DELETE", "/api/v1/users/"+other.Id, otherToken, nil))
+	})
+
+	t.Run("Gin update project keeps its author and creation time", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.PUT("/api/v1/projects/:id", auth.Authorize, handler.PutProject)
+
+		owner, err := svc.CreateUser(context.Background(), &domain.User{FirstName: "Antony", LastName: "Injila", Email: "author@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), owner.Id)
+		project, err := svc.CreateProject(context.Background(), &domain.Project{UserID: owner.Id, Title: "Go gRPC for beginners"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, _ := auth.GenerateToken(context.Background(), owner.Id)
+
+		jsonValue, _ := json.Marshal(map[string]interface{}{
+			"title":      "Go gRPC in depth",
+			"user_id":    "someone-else",
+			"user_name":  "Someone Else",
+			"user_title": "CEO",
+			"created_at": 1,
+		})
+		req, _ := http.NewRequest("PUT", "/api/v1/projects/"+project.Id, bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("token", token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		res, err := svc.ReadProject(context.Background(), project.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Go gRPC in depth", res.Title)
+		assert.Equal(t, project.UserID, res.UserID)
+		assert.Equal(t, project.UserName, res.UserName)
+		assert.Equal(t, project.UserTitle, res.UserTitle)
+		assert.Equal(t, project.CreateAt, res.CreateAt)
+	})
+
+	t.Run("Gin Logout", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.POST("/api/v1/logout", auth.Authorize, handler.Logout)
+		r.PUT("/api/v1/users/:id", auth.Authorize, handler.PutUser)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "logout@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+		token, err := auth.GenerateToken(context.Background(), user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := auth.GenerateToken(context.Background(), user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send := func(method, url, token string) int {
+			jsonValue, _ := json.Marshal(user)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("token", token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusOK, send("POST", "/api/v1/logout", token))
+		assert.Equal(t, http.StatusUnauthorized, send("PUT", "/api/v1/users/"+user.Id, token))
+		assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/v1/logout", token))
+		// Other sessions of the user stay logged in
+		assert.Equal(t, http.StatusOK, send("PUT", "/api/v1/users/"+user.Id, other))
+	})
+
+	t.Run("Gin Refresh token", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.POST("/api/v1/login", handler.Login)
+		r.POST("/api/v1/logout", auth.Authorize, handler.Logout)
+		r.POST("/api/v1/token/refresh", handler.RefreshToken)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "session@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		type session struct {
+			AccessToken  string `json:"accessToken"`
+			RefreshToken string `json:"refreshToken"`
+		}
+		send := func(url string, body interface{}, accessToken string) (int, session) {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			if accessToken != "" {
+				req.Header.Set("token", accessToken)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			var res session
+			json.Unmarshal(w.Body.Bytes(), &res)
+			return w.Code, res
+		}
+
+		code, login := send("/api/v1/login", gin.H{"email": "session@gmail.com", "password": "password"}, "")
+		assert.Equal(t, http.StatusOK, code)
+		if login.RefreshToken == "" {
+			t.Fatal("login returned no refresh token")
+		}
+
+		code, refreshed := send("/api/v1/token/refresh", gin.H{"refreshToken": login.RefreshToken}, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+		// Replaying the first refresh token logs out every session of the login
+		code, _ = send("/api/v1/token/refresh", gin.H{"refreshToken": login.RefreshToken}, "")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _ = send("/api/v1/token/refresh", gin.H{"refreshToken": refreshed.RefreshToken}, "")
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		// Logging out revokes the refresh token as well
+		_, login = send("/api/v1/login", gin.H{"email": "session@gmail.com", "password": "password"}, "")
+		code, _ = send("/api/v1/logout", gin.H{"refreshToken": login.RefreshToken}, login.AccessToken)
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = send("/api/v1/token/refresh", gin.H{"refreshToken": login.RefreshToken}, "")
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("Gin Secure session cookies", func(t *testing.T) {
+		r := SetUpRouter()
+		r.POST("/api/v1/login", NewGinHandler(*svc, keys, true).Login)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "secure-cookies@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		jsonValue, _ := json.Marshal(gin.H{"email": "secure-cookies@gmail.com", "password": "password"})
+		req, _ := http.NewRequest("POST", "/api/v1/login", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		cookies := w.Result().Cookies()
+		assert.Equal(t, 3, len(cookies))
+		for _, cookie := range cookies {
+			if !cookie.Secure {
+				t.Errorf("login set cookie %s without Secure", cookie.Name)
+			}
+		}
+	})
+
+	t.Run("Gin Bearer and cookie authentication", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.POST("/api/v1/login", handler.Login)
+		r.POST("/api/v1/token/refresh", handler.RefreshToken)
+		r.GET("/api/v1/session", auth.Authorize, func(ctx *gin.Context) {
+			ctx.Status(http.StatusNoContent)
+		})
+		r.PUT("/api/v1/users/:id", auth.Authorize, handler.PutUser)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "cookies@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		jsonValue, _ := json.Marshal(gin.H{"email": "cookies@gmail.com", "password": "password"})
+		req, _ := http.NewRequest("POST", "/api/v1/login", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var login struct {
+			AccessToken string `json:"accessToken"`
+			CSRFToken   string `json:"csrfToken"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &login)
+		cookies := w.Result().Cookies()
+		var csrfCookie *http.Cookie
+		for _, cookie := range cookies {
+			if cookie.Name == middleware.CSRFCookie {
+				csrfCookie = cookie
+			}
+		}
+		if csrfCookie == nil || csrfCookie.HttpOnly || csrfCookie.Value != login.CSRFToken {
+			t.Fatalf("login set CSRF cookie %v, want a readable cookie with %q", csrfCookie, login.CSRFToken)
+		}
+
+		send := func(method, url string, header http.Header, withCookies bool) int {
+			jsonValue, _ := json.Marshal(user)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			for name, values := range header {
+				req.Header.Set(name, values[0])
+			}
+			if withCookies {
+				for _, cookie := range cookies {
+					req.AddCookie(cookie)
+				}
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		userURL := "/api/v1/users/" + user.Id
+
+		assert.Equal(t, http.StatusNoContent, send("GET", "/api/v1/session", http.Header{"Authorization": {"Bearer " + login.AccessToken}}, false))
+		assert.Equal(t, http.StatusOK, send("PUT", userURL, http.Header{"Authorization": {"bearer " + login.AccessToken}}, false))
+		assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/session", http.Header{"Authorization": {"Basic " + login.AccessToken}}, false))
+		assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/session", http.Header{"Authorization": {"Bearer not-a-token"}}, false))
+
+		// Cookies authenticate safe requests as they are, other requests
+		// must repeat the CSRF cookie in the header
+		assert.Equal(t, http.StatusNoContent, send("GET", "/api/v1/session", nil, true))
+		assert.Equal(t, http.StatusForbidden, send("PUT", userURL, nil, true))
+		assert.Equal(t, http.StatusForbidden, send("PUT", userURL, http.Header{middleware.CSRFHeader: {"guess"}}, true))
+		assert.Equal(t, http.StatusOK, send("PUT", userURL, http.Header{middleware.CSRFHeader: {login.CSRFToken}}, true))
+		// Requests with a Bearer token aren't checked, browsers don't add it
+		assert.Equal(t, http.StatusOK, send("PUT", userURL, http.Header{"Authorization": {"Bearer " + login.AccessToken}}, true))
+
+		assert.Equal(t, http.StatusForbidden, send("POST", "/api/v1/token/refresh", nil, true))
+		assert.Equal(t, http.StatusOK, send("POST", "/api/v1/token/refresh", http.Header{middleware.CSRFHeader: {login.CSRFToken}}, true))
+	})
+
+	t.Run("Gin Reset password", func(t *testing.T) {
+		r := SetUpRouter()
+		r.POST("/api/v1/login", handler.Login)
+		r.POST("/api/v1/password/forgot", handler.ForgotPassword)
+		r.POST("/api/v1/password/reset", handler.ResetPassword)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "reset@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		send := func(url string, body interface{}) int {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		// Unknown emails get the same response
+		assert.Equal(t, http.StatusAccepted, send("/api/v1/password/forgot", gin.H{"email": "nobody@gmail.com"}))
+		assert.Equal(t, http.StatusAccepted, send("/api/v1/password/forgot", gin.H{"email": "reset@gmail.com"}))
+
+		mail := mailbox.last()
+		if mail == nil || mail.To != "reset@gmail.com" {
+			t.Fatal("password reset link was not mailed")
+		}
+		match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(mail.Body)
+		if match == nil {
+			t.Fatalf("no password reset link in %q", mail.Body)
+		}
+		token, _ := url.QueryUnescape(match[1])
+
+		assert.Equal(t, http.StatusBadRequest, send("/api/v1/password/reset", gin.H{"token": "not a token", "password": "new password"}))
+		assert.Equal(t, http.StatusOK, send("/api/v1/password/reset", gin.H{"token": token, "password": "new password"}))
+		assert.Equal(t, http.StatusBadRequest, send("/api/v1/password/reset", gin.H{"token": token, "password": "other password"}))
+
+		assert.Equal(t, http.StatusUnauthorized, send("/api/v1/login", gin.H{"email": "reset@gmail.com", "password": "password"}))
+		assert.Equal(t, http.StatusOK, send("/api/v1/login", gin.H{"email": "reset@gmail.com", "password": "new password"}))
+	})
+
+	t.Run("Gin Verify email", func(t *testing.T) {
+		strict := options
+		strict.RequireVerifiedEmail = services.VerifyForLogin
+		handler := NewGinHandler(*services.NewPortfolioService(&repo, mailbox, strict), keys, false)
+		r := SetUpRouter()
+		r.POST("/api/v1/signup", handler.Signup)
+		r.POST("/api/v1/login", handler.Login)
+		r.GET("/api/v1/verify", handler.VerifyEmail)
+
+		send := func(url string, body interface{}) int {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		verify := func(token string) int {
+			req, _ := http.NewRequest("GET", "/api/v1/verify?token="+url.QueryEscape(token), nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusCreated, send("/api/v1/signup", gin.H{"email": "verify@gmail.com", "password": "password"}))
+		user, err := svc.ReadUserWithEmail(context.Background(), "verify@gmail.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		mail := mailbox.last()
+		if mail == nil || mail.To != "verify@gmail.com" {
+			t.Fatal("verification link was not mailed")
+		}
+		match := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(mail.Body)
+		if match == nil {
+			t.Fatalf("no verification link in %q", mail.Body)
+		}
+		token, _ := url.QueryUnescape(match[1])
+
+		assert.Equal(t, http.StatusForbidden, send("/api/v1/login", gin.H{"email": "verify@gmail.com", "password": "password"}))
+		assert.Equal(t, http.StatusBadRequest, verify("not a token"))
+		assert.Equal(t, http.StatusOK, verify(token))
+		assert.Equal(t, http.StatusOK, send("/api/v1/login", gin.H{"email": "verify@gmail.com", "password": "password"}))
+	})
+
+	t.Run("Gin Login with two-factor authentication", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.POST("/api/v1/login", handler.Login)
+		r.POST("/api/v1/login/2fa", handler.LoginTwoFactor)
+		r.POST("/api/v1/users/:id/2fa", auth.Authorize, handler.PostTOTP)
+		r.POST("/api/v1/users/:id/2fa/confirm", auth.Authorize, handler.ConfirmTOTP)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "2fa@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+		token, err := auth.GenerateToken(context.Background(), user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send := func(url string, body interface{}, res interface{}) int {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("token", token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if res != nil {
+				json.Unmarshal(w.Body.Bytes(), res)
+			}
+			return w.Code
+		}
+
+		var enrollment struct {
+			Secret string `json:"secret"`
+		}
+		assert.Equal(t, http.StatusOK, send("/api/v1/users/"+user.Id+"/2fa", nil, &enrollment))
+		var confirmation struct {
+			RecoveryCodes []string `json:"recoveryCodes"`
+		}
+		assert.Equal(t, http.StatusOK, send("/api/v1/users/"+user.Id+"/2fa/confirm", gin.H{"code": totpTestCode(t, enrollment.Secret, time.Now())}, &confirmation))
+		if len(confirmation.RecoveryCodes) == 0 {
+			t.Fatal("no recovery codes returned")
+		}
+
+		// The password alone gets a challenge, not a session
+		var login struct {
+			MFARequired bool   `json:"mfaRequired"`
+			MFAToken    string `json:"mfaToken"`
+			AccessToken string `json:"accessToken"`
+		}
+		assert.Equal(t, http.StatusOK, send("/api/v1/login", gin.H{"email": "2fa@gmail.com", "password": "password"}, &login))
+		if !login.MFARequired || login.MFAToken == "" || login.AccessToken != "" {
+			t.Fatalf("login of a user with two-factor authentication returned %+v", login)
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, send("/api/v1/login/2fa", gin.H{"mfaToken": login.MFAToken, "code": "abcdef"}, nil))
+		var session struct {
+			AccessToken string `json:"accessToken"`
+		}
+		assert.Equal(t, http.StatusOK, send("/api/v1/login/2fa", gin.H{"mfaToken": login.MFAToken, "code": confirmation.RecoveryCodes[0]}, &session))
+		if session.AccessToken == "" {
+			t.Error("no access token after the second step")
+		}
+	})
+
+	t.Run("Gin Login after too many failures", func(t *testing.T) {
+		throttled := options
+		throttled.LoginAttempts = repository.NewInMemoryLoginAttemptStore()
+		handler := NewGinHandler(*services.NewPortfolioService(&repo, mailbox, throttled), keys, false)
+		r := SetUpRouter()
+		r.POST("/api/v1/login", handler.Login)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "guess@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		login := func(password string) *httptest.ResponseRecorder {
+			jsonValue, _ := json.Marshal(gin.H{"email": "guess@gmail.com", "password": password})
+			req, _ := http.NewRequest("POST", "/api/v1/login", bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, login("wrong").Code)
+		}
+		w := login("password")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		// The wait is a second, rounded up from when the last failure was stored
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != "1" && retryAfter != "2" {
+			t.Errorf("Retry-After is %q, want about a second", retryAfter)
+		}
+	})
+
+	t.Run("Gin requests guarded by roles", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.GET("/api/v1/users", auth.Authorize, middleware.Require(middleware.ListUsers), handler.GetUsers)
+		r.PUT("/api/v1/users/:id/role", auth.Authorize, handler.PutUserRole)
+		r.DELETE("/api/v1/users/:id", auth.Authorize, handler.DeleteUser)
+		r.POST("/api/v1/projects", auth.Authorize, handler.PostProject)
+
+		newUser := func(email string, role domain.Role) (*domain.User, string) {
+			user, err := svc.CreateUser(context.Background(), &domain.User{Email: email, Password: "password"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user, err = svc.SetUserRole(context.Background(), user.Id, role); err != nil {
+				t.Fatal(err)
+			}
+			token, err := auth.GenerateToken(context.Background(), user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return user, token
+		}
+		admin, adminToken := newUser("admin@gmail.com", domain.RoleAdmin)
+		owner, ownerToken := newUser("roles-owner@gmail.com", domain.RoleOwner)
+		viewer, viewerToken := newUser("viewer@gmail.com", domain.RoleViewer)
+		defer svc.DeleteUser(context.Background(), admin.Id)
+
+		send := func(method, url, token string, body interface{}) int {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("token", token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusForbidden, send("GET", "/api/v1/users", ownerToken, nil))
+		assert.Equal(t, http.StatusOK, send("GET", "/api/v1/users", adminToken, nil))
+
+		assert.Equal(t, http.StatusForbidden, send("POST", "/api/v1/projects", viewerToken, domain.Project{Title: "Go gRPC for beginners"}))
+		assert.Equal(t, http.StatusCreated, send("POST", "/api/v1/projects", ownerToken, domain.Project{Title: "Go gRPC for beginners"}))
+
+		assert.Equal(t, http.StatusForbidden, send("PUT", "/api/v1/users/"+owner.Id+"/role", ownerToken, gin.H{"role": "admin"}))
+		assert.Equal(t, http.StatusBadRequest, send("PUT", "/api/v1/users/"+owner.Id+"/role", adminToken, gin.H{"role": "superuser"}))
+		assert.Equal(t, http.StatusOK, send("PUT", "/api/v1/users/"+viewer.Id+"/role", adminToken, gin.H{"role": "owner"}))
+
+		assert.Equal(t, http.StatusForbidden, send("DELETE", "/api/v1/users/"+viewer.Id, ownerToken, nil))
+		assert.Equal(t, http.StatusOK, send("DELETE", "/api/v1/users/"+viewer.Id, adminToken, nil))
+		assert.Equal(t, http.StatusOK, send("DELETE", "/api/v1/users/"+owner.Id, adminToken, nil))
+	})
+
+	t.Run("Gin Certifications", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.GET("/api/v1/users/:id", handler.GetUser)
+		r.GET("/api/v1/users/:id/certifications", handler.GetCertifications)
+		r.GET("/api/v1/users/:id/certifications/:certification", handler.GetCertification)
+		r.POST("/api/v1/users/:id/certifications", auth.Authorize, handler.PostCertification)
+		r.PUT("/api/v1/users/:id/certifications/:certification", auth.Authorize, handler.PutCertification)
+		r.DELETE("/api/v1/users/:id/certifications/:certification", auth.Authorize, handler.DeleteCertification)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "certified@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+		other, err := svc.CreateUser(context.Background(), &domain.User{Email: "uncertified@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), other.Id)
+		token, _ := auth.GenerateToken(context.Background(), user.Id)
+		otherToken, _ := auth.GenerateToken(context.Background(), other.Id)
+
+		send := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("token", token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		certificationsURL := "/api/v1/users/" + user.Id + "/certifications"
+		certification := gin.H{"title": "CKAD", "institution": "CNCF", "issued_date": "2023-06", "description": "Kubernetes"}
+
+		assert.Equal(t, http.StatusUnauthorized, send("POST", certificationsURL, "", certification).Code)
+		assert.Equal(t, http.StatusForbidden, send("POST", certificationsURL, otherToken, certification).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", certificationsURL, token, gin.H{"institution": "CNCF"}).Code)
+		w := send("POST", certificationsURL, token, certification)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var created domain.Certification
+		json.Unmarshal(w.Body.Bytes(), &created)
+		assert.Equal(t, user.Id, created.UserID)
+		assert.Equal(t, "Kubernetes", created.Description)
+
+		// Certifications are public like the rest of the profile
+		w = send("GET", certificationsURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var listed struct {
+			Certifications []domain.Certification `json:"certifications"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &listed)
+		assert.Equal(t, 1, len(listed.Certifications))
+		w = send("GET", "/api/v1/users/"+user.Id, "", nil)
+		var profile UserResponse
+		json.Unmarshal(w.Body.Bytes(), &profile)
+		assert.Equal(t, 1, len(profile.Certifications))
+
+		certificationURL := certificationsURL + "/" + created.Id
+		assert.Equal(t, http.StatusForbidden, send("PUT", certificationURL, otherToken, certification).Code)
+		assert.Equal(t, http.StatusNotFound, send("GET", "/api/v1/users/"+other.Id+"/certifications/"+created.Id, "", nil).Code)
+		certification["title"] = "Certified Kubernetes Application Developer"
+		assert.Equal(t, http.StatusOK, send("PUT", certificationURL, token, certification).Code)
+		w = send("GET", certificationURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, true, strings.Contains(w.Body.String(), "Certified Kubernetes Application Developer"))
+
+		assert.Equal(t, http.StatusForbidden, send("DELETE", certificationURL, otherToken, nil).Code)
+		assert.Equal(t, http.StatusOK, send("DELETE", certificationURL, token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, send("GET", certificationURL, "", nil).Code)
 	})
+
+	t.Run("Gin Experience and education", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.GET("/api/v1/users/:id", handler.GetUser)
+		r.GET("/api/v1/users/:id/experiences", handler.GetExperiences)
+		r.GET("/api/v1/users/:id/experiences/:experience", handler.GetExperience)
+		r.POST("/api/v1/users/:id/experiences", auth.Authorize, handler.PostExperience)
+		r.PUT("/api/v1/users/:id/experiences/:experience", auth.Authorize, handler.PutExperience)
+		r.DELETE("/api/v1/users/:id/experiences/:experience", auth.Authorize, handler.DeleteExperience)
+		r.GET("/api/v1/users/:id/education", handler.GetEducations)
+		r.GET("/api/v1/users/:id/education/:education", handler.GetEducation)
+		r.POST("/api/v1/users/:id/education", auth.Authorize, handler.PostEducation)
+		r.PUT("/api/v1/users/:id/education/:education", auth.Authorize, handler.PutEducation)
+		r.DELETE("/api/v1/users/:id/education/:education", auth.Authorize, handler.DeleteEducation)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "timeline@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+		other, err := svc.CreateUser(context.Background(), &domain.User{Email: "no-timeline@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), other.Id)
+		token, _ := auth.GenerateToken(context.Background(), user.Id)
+		otherToken, _ := auth.GenerateToken(context.Background(), other.Id)
+
+		send := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("token", token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		experiencesURL := "/api/v1/users/" + user.Id + "/experiences"
+		experience := gin.H{"company": "Andela", "role": "Software Engineer", "start_date": "2021-09", "achievements": []string{"Built the billing API"}}
+
+		assert.Equal(t, http.StatusUnauthorized, send("POST", experiencesURL, "", experience).Code)
+		assert.Equal(t, http.StatusForbidden, send("POST", experiencesURL, otherToken, experience).Code)
+		assert.Equal(t, http.StatusBadRequest, send("POST", experiencesURL, token, gin.H{"company": "Andela"}).Code)
+		w := send("POST", experiencesURL, token, experience)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var current domain.Experience
+		json.Unmarshal(w.Body.Bytes(), &current)
+		assert.Equal(t, user.Id, current.UserID)
+		assert.Equal(t, []string{"Built the billing API"}, current.Achievements)
+		w = send("POST", experiencesURL, token, gin.H{"company": "Safaricom", "role": "Backend Engineer", "start_date": "2019-01", "end_date": "2021-08"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var past domain.Experience
+		json.Unmarshal(w.Body.Bytes(), &past)
+
+		// The timeline is public like the rest of the profile, current job first
+		w = send("GET", experiencesURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var experiences struct {
+			Experiences []domain.Experience `json:"experiences"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &experiences)
+		assert.Equal(t, 2, len(experiences.Experiences))
+		if len(experiences.Experiences) == 2 {
+			assert.Equal(t, current.Id, experiences.Experiences[0].Id)
+			assert.Equal(t, past.Id, experiences.Experiences[1].Id)
+		}
+
+		experienceURL := experiencesURL + "/" + current.Id
+		assert.Equal(t, http.StatusForbidden, send("PUT", experienceURL, otherToken, experience).Code)
+		assert.Equal(t, http.StatusNotFound, send("GET", "/api/v1/users/"+other.Id+"/experiences/"+current.Id, "", nil).Code)
+		experience["end_date"] = "2021-01"
+		assert.Equal(t, http.StatusBadRequest, send("PUT", experienceURL, token, experience).Code)
+		experience["end_date"] = "2024-02"
+		assert.Equal(t, http.StatusOK, send("PUT", experienceURL, token, experience).Code)
+		w = send("GET", experienceURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, true, strings.Contains(w.Body.String(), "2024-02"))
+
+		educationURL := "/api/v1/users/" + user.Id + "/education"
+		education := gin.H{"institution": "University of Nairobi", "degree": "BSc", "field_of_study": "Computer Science", "start_date": "2014-09", "end_date": "2018-06"}
+		assert.Equal(t, http.StatusForbidden, send("POST", educationURL, otherToken, education).Code)
+		w = send("POST", educationURL, token, education)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var degree domain.Education
+		json.Unmarshal(w.Body.Bytes(), &degree)
+		assert.Equal(t, "Computer Science", degree.FieldOfStudy)
+		w = send("GET", educationURL, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, true, strings.Contains(w.Body.String(), `"education":[`))
+
+		w = send("GET", "/api/v1/users/"+user.Id, "", nil)
+		var profile UserResponse
+		json.Unmarshal(w.Body.Bytes(), &profile)
+		assert.Equal(t, 2, len(profile.Experiences))
+		assert.Equal(t, 1, len(profile.Education))
+
+		degreeURL := educationURL + "/" + degree.Id
+		assert.Equal(t, http.StatusForbidden, send("DELETE", degreeURL, otherToken, nil).Code)
+		assert.Equal(t, http.StatusOK, send("DELETE", degreeURL, token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, send("GET", degreeURL, "", nil).Code)
+		assert.Equal(t, http.StatusOK, send("DELETE", experienceURL, token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, send("GET", experienceURL, "", nil).Code)
+	})
+
+	t.Run("Gin API keys", func(t *testing.T) {
+		auth := middleware.NewMiddleware(svc, keys)
+		r := SetUpRouter()
+		r.POST("/api/v1/users/:id/api-keys", auth.Authorize, handler.PostAPIKey)
+		r.GET("/api/v1/users/:id/api-keys", auth.Authorize, handler.GetAPIKeys)
+		r.DELETE("/api/v1/users/:id/api-keys/:key", auth.Authorize, handler.DeleteAPIKey)
+		r.POST("/api/v1/projects", auth.Authorize, handler.PostProject)
+		r.DELETE("/api/v1/projects/:id", auth.Authorize, handler.DeleteProject)
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "apikeys@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+		token, err := auth.GenerateToken(context.Background(), user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		send := func(method, url, authorization string, body interface{}) *httptest.ResponseRecorder {
+			jsonValue, _ := json.Marshal(body)
+			req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+			req.Header.Set("Content-Type", "application/json")
+			if strings.HasPrefix(authorization, "ApiKey ") {
+				req.Header.Set("Authorization", authorization)
+			} else {
+				req.Header.Set("token", authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		keysURL := "/api/v1/users/" + user.Id + "/api-keys"
+
+		assert.Equal(t, http.StatusBadRequest, send("POST", keysURL, token, gin.H{"name": "CI", "scopes": []string{"everything"}}).Code)
+		w := send("POST", keysURL, token, gin.H{"name": "CI", "scopes": []string{"projects:write"}})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var created struct {
+			Key    string         `json:"key"`
+			APIKey APIKeyResponse `json:"api_key"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		apiKey := "ApiKey " + created.Key
+
+		w = send("GET", keysURL, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, false, strings.Contains(w.Body.String(), created.Key))
+		assert.Equal(t, true, strings.Contains(w.Body.String(), created.APIKey.Id))
+
+		// Keys do what their scopes allow and nothing else
+		w = send("POST", "/api/v1/projects", apiKey, domain.Project{Title: "Go gRPC for beginners"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var project domain.Project
+		json.Unmarshal(w.Body.Bytes(), &project)
+		assert.Equal(t, http.StatusForbidden, send("DELETE", "/api/v1/projects/"+project.Id, apiKey, nil).Code)
+		assert.Equal(t, http.StatusForbidden, send("POST", keysURL, apiKey, gin.H{"name": "More", "scopes": []string{"projects:delete"}}).Code)
+		assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/v1/projects", "ApiKey "+created.APIKey.Id+".secret", domain.Project{}).Code)
+
+		assert.Equal(t, http.StatusOK, send("DELETE", keysURL+"/"+created.APIKey.Id, token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, send("DELETE", keysURL+"/"+created.APIKey.Id, token, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, send("POST", "/api/v1/projects", apiKey, domain.Project{}).Code)
+	})
+
+	t.Run("Gin Login with an identity provider", func(t *testing.T) {
+		idp := oauthtest.NewServer(oauthtest.User{Subject: "7", Email: "provider@gmail.com", EmailVerified: true, GivenName: "Ada"})
+		defer idp.Close()
+		provider, err := oauth.NewOIDCProvider(context.Background(), "oidc", idp.URL, "client-id", "client-secret", "http://localhost:8081/api/v1/login/oidc/callback")
+		if err != nil {
+			t.Fatal(err)
+		}
+		withProviders := options
+		withProviders.IdentityProviders = map[string]ports.IdentityProvider{"oidc": provider}
+		handler := NewGinHandler(*services.NewPortfolioService(&repo, mailbox, withProviders), keys, false)
+		r := SetUpRouter()
+		r.GET("/api/v1/login/:provider", handler.LoginWithProvider)
+		r.GET("/api/v1/login/:provider/callback", handler.LoginCallback)
+
+		req, _ := http.NewRequest("GET", "/api/v1/login/gitlab", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		req, _ = http.NewRequest("GET", "/api/v1/login/oidc", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		cookies := w.Result().Cookies()
+
+		// The user logs in at the provider, which redirects to the callback
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		res, err := client.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		callback, err := url.Parse(res.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "/api/v1/login/oidc/callback", callback.Path)
+
+		login := func(query string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", callback.Path+"?"+query, nil)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		// The callback only works in the browser that started the login
+		assert.Equal(t, http.StatusBadRequest, login(callback.RawQuery, nil).Code)
+		forged := callback.Query()
+		forged.Set("state", "forged")
+		assert.Equal(t, http.StatusBadRequest, login(forged.Encode(), cookies).Code)
+
+		w = login(callback.RawQuery, cookies)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var session struct{ AccessToken string }
+		json.Unmarshal(w.Body.Bytes(), &session)
+		if session.AccessToken == "" {
+			t.Errorf("login responded without an access token: %s", w.Body.String())
+		}
+		user, err := svc.ReadUserWithEmail(context.Background(), "provider@gmail.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+		assert.Equal(t, "Ada", user.FirstName)
+		assert.Equal(t, true, user.EmailVerified)
+
+		// The provider refuses a code used before
+		assert.Equal(t, http.StatusUnauthorized, login(callback.RawQuery, cookies).Code)
+	})
+
+	t.Run("Gin tokens signed with rotated keys", func(t *testing.T) {
+		rsaKey, err := middleware.GenerateSigningKey(jwt.SigningMethodRS256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy, err := middleware.NewSigningKey(jwt.SigningMethodHS256, []byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		legacy.ExpiresAt = time.Now().Add(time.Minute)
+		rotating := middleware.NewKeyManager(rsaKey, legacy)
+		auth := middleware.NewMiddleware(svc, rotating)
+		r := SetUpRouter()
+		r.GET("/.well-known/jwks.json", rotating.ServeJWKS)
+		r.GET("/api/v1/session", auth.Authorize, func(ctx *gin.Context) {
+			ctx.Status(http.StatusNoContent)
+		})
+
+		user, err := svc.CreateUser(context.Background(), &domain.User{Email: "rotate@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(context.Background(), user.Id)
+
+		newToken := func(auth interface {
+			GenerateToken(context.Context, string) (string, error)
+		}) string {
+			token, err := auth.GenerateToken(context.Background(), user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}
+		send := func(token string) int {
+			req, _ := http.NewRequest("GET", "/api/v1/session", nil)
+			req.Header.Set("token", token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		jwks := func() []middleware.JWK {
+			req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var body struct{ Keys []middleware.JWK }
+			json.Unmarshal(w.Body.Bytes(), &body)
+			return body.Keys
+		}
+
+		rsaToken := newToken(auth)
+		parsed, _, err := new(jwt.Parser).ParseUnverified(rsaToken, jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "RS256", parsed.Header["alg"])
+		assert.Equal(t, rsaKey.ID, parsed.Header["kid"])
+		assert.Equal(t, http.StatusNoContent, send(rsaToken))
+		// Tokens signed with the secret before switching algorithms still
+		// verify for the overlap, tokens signed with an unknown key never do
+		assert.Equal(t, http.StatusNoContent, send(newToken(middleware.NewMiddleware(svc, keys))))
+		other, _ := middleware.NewHMACKeyManager([]byte("other-secret"))
+		assert.Equal(t, http.StatusUnauthorized, send(newToken(middleware.NewMiddleware(svc, other))))
+
+		// The secret is never published
+		published := jwks()
+		assert.Equal(t, 1, len(published))
+		assert.Equal(t, "RSA", published[0].Kty)
+		assert.Equal(t, rsaKey.ID, published[0].Kid)
+
+		edKey, err := middleware.GenerateSigningKey(jwt.SigningMethodEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotating.Rotate(edKey, time.Minute)
+		edToken := newToken(auth)
+		assert.Equal(t, http.StatusNoContent, send(edToken))
+		assert.Equal(t, http.StatusNoContent, send(rsaToken))
+		published = jwks()
+		assert.Equal(t, 2, len(published))
+		assert.Equal(t, "OKP", published[0].Kty)
+		assert.Equal(t, "Ed25519", published[0].Crv)
+
+		// Without an overlap the previous key stops verifying right away
+		next, err := middleware.GenerateSigningKey(jwt.SigningMethodEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotating.Rotate(next, 0)
+		assert.Equal(t, http.StatusUnauthorized, send(edToken))
+		assert.Equal(t, http.StatusNoContent, send(rsaToken))
+		assert.Equal(t, http.StatusNoContent, send(newToken(auth)))
+
+		// Published keys are listed before they sign tokens
+		pending, err := middleware.GenerateSigningKey(jwt.SigningMethodEdDSA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := len(jwks())
+		rotating.Publish(pending)
+		published = jwks()
+		assert.Equal(t, before+1, len(published))
+		assert.Equal(t, pending.ID, published[len(published)-1].Kid)
+		parsed, _, err = new(jwt.Parser).ParseUnverified(newToken(auth), jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, next.ID, parsed.Header["kid"])
+		rotating.Rotate(pending, time.Minute)
+		assert.Equal(t, before+1, len(jwks()))
+		parsed, _, err = new(jwt.Parser).ParseUnverified(newToken(auth), jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, pending.ID, parsed.Header["kid"])
+	})
+
 	// t.Run("Gin Read all user", func(t *testing.T) {
 	// 	r := SetUpRouter()
 	// 	r.GET("/api/v1/users", handler.GetUsers)
@@ -91,3 +1188,42 @@ func TestApplicationRoutes(t *testing.T) {
 	// })
 
 }
+
+// totpTestCode returns the RFC 6238 code of secret at now, as an
+// authenticator app would show it.
+func totpTestCode(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(now.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
+
+// testMailer keeps the emails the service sends.
+type testMailer struct {
+	mu    sync.Mutex
+	mails []*domain.Mail
+}
+
+func (m *testMailer) Send(ctx context.Context, mail *domain.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func (m *testMailer) last() *domain.Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.mails) == 0 {
+		return nil
+	}
+	return m.mails[len(m.mails)-1]
+}
@@ -0,0 +1,247 @@
+/*
+Package name : gin
+File name : dto.go
+Author : Antony Injila
+Description :
+	- Host the request and response bodies of the users API
+	- Passwords are read from requests but never written to responses
+	- API keys are written once, in the response to creating them
+*/
+
+package gin
+
+import (
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+// SignupRequest is the body of Signup and PostUser.
+type SignupRequest struct {
+	FirstName string `json:"firstname" form:"firstname"`
+	LastName  string `json:"lastname" form:"lastname"`
+	Email     string `json:"email" form:"email" binding:"required"`
+	Title     string `json:"title" form:"title"`
+	Password  string `json:"password" form:"password" binding:"required"`
+}
+
+func (r SignupRequest) user() *domain.User {
+	return &domain.User{
+		FirstName: r.FirstName,
+		LastName:  r.LastName,
+		Email:     r.Email,
+		Title:     r.Title,
+		Password:  r.Password,
+	}
+}
+
+// LoginRequest is the body of Login.
+type LoginRequest struct {
+	Email    string `json:"email" form:"email" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// UpdateUserRequest is the body of PutUser. Passwords are changed with a
+// password reset link, projects and certifications with their own API.
+type UpdateUserRequest struct {
+	FirstName string `json:"firstname"`
+	LastName  string `json:"lastname"`
+	Email     string `json:"email" binding:"required"`
+	Title     string `json:"title"`
+}
+
+func (r UpdateUserRequest) user(id string) *domain.User {
+	return &domain.User{
+		Id:        id,
+		FirstName: r.FirstName,
+		LastName:  r.LastName,
+		Email:     r.Email,
+		Title:     r.Title,
+	}
+}
+
+// UserResponse is a user as the API returns it, without credentials.
+type UserResponse struct {
+	Id             string                  `json:"id"`
+	FirstName      string                  `json:"firstname"`
+	LastName       string                  `json:"lastname"`
+	Email          string                  `json:"email"`
+	Title          string                  `json:"title"`
+	Role           domain.Role             `json:"role"`
+	EmailVerified  bool                    `json:"email_verified"`
+	TOTPEnabled    bool                    `json:"totp_enabled"`
+	Projects       []*domain.Project       `json:"projects"`
+	Certifications []*domain.Certification `json:"certifications"`
+	Experiences    []*domain.Experience    `json:"experiences"`
+	Education      []*domain.Education     `json:"education"`
+}
+
+func newUserResponse(user *domain.User) UserResponse {
+	return UserResponse{
+		Id:             user.Id,
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		Email:          user.Email,
+		Title:          user.Title,
+		Role:           user.Role,
+		EmailVerified:  user.EmailVerified,
+		TOTPEnabled:    user.TOTPEnabled,
+		Projects:       user.Projects,
+		Certifications: user.Certifications,
+		Experiences:    user.Experiences,
+		Education:      user.Education,
+	}
+}
+
+func newUserResponses(users []*domain.User) []UserResponse {
+	res := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		res = append(res, newUserResponse(user))
+	}
+	return res
+}
+
+// CreateProjectRequest is the body of PostProject. Projects without user_id
+// belong to the authenticated user.
+type CreateProjectRequest struct {
+	UserID string `json:"user_id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Rate   int    `json:"rate"`
+}
+
+func (r CreateProjectRequest) project() *domain.Project {
+	return &domain.Project{
+		UserID: r.UserID,
+		Title:  r.Title,
+		Body:   r.Body,
+		Rate:   r.Rate,
+	}
+}
+
+// UpdateProjectRequest is the body of PutProject. The owner and creation
+// time of a project don't change.
+type UpdateProjectRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Rate  int    `json:"rate"`
+}
+
+// project returns existing with the changes of the request.
+func (r UpdateProjectRequest) project(existing *domain.Project) *domain.Project {
+	return &domain.Project{
+		Id:        existing.Id,
+		UserID:    existing.UserID,
+		Title:     r.Title,
+		Body:      r.Body,
+		UserName:  existing.UserName,
+		UserTitle: existing.UserTitle,
+		Rate:      r.Rate,
+		CreateAt:  existing.CreateAt,
+	}
+}
+
+// CertificationRequest is the body of PostCertification and
+// PutCertification. The user a certification belongs to comes from the path.
+type CertificationRequest struct {
+	Title          string `json:"title"`
+	Institution    string `json:"institution"`
+	State          string `json:"state"`
+	IssuedDate     string `json:"issued_date"`
+	CredentialLink string `json:"credential_link"`
+	Description    string `json:"description"`
+}
+
+func (r CertificationRequest) certification(userID, id string) *domain.Certification {
+	return &domain.Certification{
+		Id:             id,
+		UserID:         userID,
+		Title:          r.Title,
+		Institution:    r.Institution,
+		State:          r.State,
+		IssuedDate:     r.IssuedDate,
+		CredentialLink: r.CredentialLink,
+		Description:    r.Description,
+	}
+}
+
+// ExperienceRequest is the body of PostExperience and PutExperience.
+type ExperienceRequest struct {
+	Company      string   `json:"company"`
+	Role         string   `json:"role"`
+	Location     string   `json:"location"`
+	StartDate    string   `json:"start_date"`
+	EndDate      string   `json:"end_date"`
+	Achievements []string `json:"achievements"`
+}
+
+func (r ExperienceRequest) experience(userID, id string) *domain.Experience {
+	return &domain.Experience{
+		Id:           id,
+		UserID:       userID,
+		Company:      r.Company,
+		Role:         r.Role,
+		Location:     r.Location,
+		StartDate:    r.StartDate,
+		EndDate:      r.EndDate,
+		Achievements: r.Achievements,
+	}
+}
+
+// EducationRequest is the body of PostEducation and PutEducation.
+type EducationRequest struct {
+	Institution  string `json:"institution"`
+	Degree       string `json:"degree"`
+	FieldOfStudy string `json:"field_of_study"`
+	StartDate    string `json:"start_date"`
+	EndDate      string `json:"end_date"`
+	Description  string `json:"description"`
+}
+
+func (r EducationRequest) education(userID, id string) *domain.Education {
+	return &domain.Education{
+		Id:           id,
+		UserID:       userID,
+		Institution:  r.Institution,
+		Degree:       r.Degree,
+		FieldOfStudy: r.FieldOfStudy,
+		StartDate:    r.StartDate,
+		EndDate:      r.EndDate,
+		Description:  r.Description,
+	}
+}
+
+// CreateAPIKeyRequest is the body of PostAPIKey. Keys without expires_at
+// don't expire.
+type CreateAPIKeyRequest struct {
+	Name      string    `json:"name" binding:"required"`
+	Scopes    []string  `json:"scopes" binding:"required"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse is an API key as the API lists it, without the key.
+type APIKeyResponse struct {
+	Id        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+}
+
+func newAPIKeyResponse(key *domain.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		Id:        key.Id,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+	}
+}
+
+func newAPIKeyResponses(keys []*domain.APIKey) []APIKeyResponse {
+	res := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, newAPIKeyResponse(key))
+	}
+	return res
+}
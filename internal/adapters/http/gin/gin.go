@@ -10,37 +10,60 @@ package gin
 
 import (
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/AntonyIS/portfolio-be/config"
-	// "github.com/AntonyIS/portfolio-be/internal/adapters/middleware"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/middleware"
 	"github.com/AntonyIS/portfolio-be/internal/core/services"
 	"github.com/gin-contrib/cors"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InitGinRoutes(svc services.PortfolioService, config config.AppConfig) {
+func InitGinRoutes(svc services.PortfolioService, keys *middleware.KeyManager, config config.AppConfig) {
 	// Enable detailed error responses
 	gin.SetMode(gin.DebugMode)
 
 	// Setup Gin router
 	router := gin.Default()
+	// Failed logins are counted per client address, which clients could pick
+	// with X-Forwarded-For if every proxy was trusted
+	if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	// Translate errors returned by handlers into problem details responses
+	router.Use(middleware.HandleErrors)
+	// Cancel storage calls that outlive the request deadline
+	router.Use(middleware.Timeout(config.RequestTimeout))
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "token", middleware.CSRFHeader},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
 	// Setup application route handlers
-	handler := NewGinHandler(svc)
+	handler := NewGinHandler(svc, keys, config.SecureCookies)
+	// Changes to users and projects need a token, handlers check the role
+	// in the token against the policy in the middleware adapter
+	auth := middleware.NewMiddleware(&svc, keys)
 	router.GET("/", handler.Home)
+	// Other services verify portfolio tokens with the published keys
+	router.GET("/.well-known/jwks.json", keys.ServeJWKS)
 	router.POST("/api/v1/login", handler.Login)
-	router.POST("/api/v1/logout", handler.Logout)
+	router.POST("/api/v1/login/2fa", handler.LoginTwoFactor)
+	router.GET("/api/v1/login/:provider", handler.LoginWithProvider)
+	router.GET("/api/v1/login/:provider/callback", handler.LoginCallback)
+	router.POST("/api/v1/logout", auth.Authorize, handler.Logout)
+	router.POST("/api/v1/token/refresh", handler.RefreshToken)
 	router.POST("/api/v1/signup", handler.Signup)
+	router.POST("/api/v1/password/forgot", handler.ForgotPassword)
+	router.POST("/api/v1/password/reset", handler.ResetPassword)
+	router.GET("/api/v1/verify", handler.VerifyEmail)
+	router.POST("/api/v1/verify/resend", handler.ResendVerification)
 
 	// Group users API
 	usersRoutes := router.Group("/api/v1/users")
@@ -48,26 +71,42 @@ func InitGinRoutes(svc services.PortfolioService, config config.AppConfig) {
 	// Group projects API
 	projectsRoutes := router.Group("/api/v1/projects")
 
-	// Add middleware in production
-	// if config.Env == "pro" {
-	// 	middleware := middleware.NewMiddleware(&svc)
-	// 	usersRoutes.Use(middleware.Authorize)
-	// 	projectsRoutes.Use(middleware.Authorize)
-	// }
-
 	{
-		usersRoutes.GET("/", handler.GetUsers)
+		usersRoutes.GET("/", auth.Authorize, middleware.Require(middleware.ListUsers), handler.GetUsers)
 		usersRoutes.GET("/:id", handler.GetUser)
+		// Creating a user is signing up, there is no token yet
 		usersRoutes.POST("/", handler.PostUser)
-		usersRoutes.PUT("/:id", handler.PutUser)
-		usersRoutes.DELETE("/:id", handler.DeleteUser)
+		usersRoutes.PUT("/:id", auth.Authorize, handler.PutUser)
+		usersRoutes.PUT("/:id/role", auth.Authorize, handler.PutUserRole)
+		usersRoutes.POST("/:id/2fa", auth.Authorize, handler.PostTOTP)
+		usersRoutes.POST("/:id/2fa/confirm", auth.Authorize, handler.ConfirmTOTP)
+		usersRoutes.DELETE("/:id/2fa", auth.Authorize, handler.DeleteTOTP)
+		usersRoutes.GET("/:id/certifications", handler.GetCertifications)
+		usersRoutes.GET("/:id/certifications/:certification", handler.GetCertification)
+		usersRoutes.POST("/:id/certifications", auth.Authorize, handler.PostCertification)
+		usersRoutes.PUT("/:id/certifications/:certification", auth.Authorize, handler.PutCertification)
+		usersRoutes.DELETE("/:id/certifications/:certification", auth.Authorize, handler.DeleteCertification)
+		usersRoutes.GET("/:id/experiences", handler.GetExperiences)
+		usersRoutes.GET("/:id/experiences/:experience", handler.GetExperience)
+		usersRoutes.POST("/:id/experiences", auth.Authorize, handler.PostExperience)
+		usersRoutes.PUT("/:id/experiences/:experience", auth.Authorize, handler.PutExperience)
+		usersRoutes.DELETE("/:id/experiences/:experience", auth.Authorize, handler.DeleteExperience)
+		usersRoutes.GET("/:id/education", handler.GetEducations)
+		usersRoutes.GET("/:id/education/:education", handler.GetEducation)
+		usersRoutes.POST("/:id/education", auth.Authorize, handler.PostEducation)
+		usersRoutes.PUT("/:id/education/:education", auth.Authorize, handler.PutEducation)
+		usersRoutes.DELETE("/:id/education/:education", auth.Authorize, handler.DeleteEducation)
+		usersRoutes.POST("/:id/api-keys", auth.Authorize, handler.PostAPIKey)
+		usersRoutes.GET("/:id/api-keys", auth.Authorize, handler.GetAPIKeys)
+		usersRoutes.DELETE("/:id/api-keys/:key", auth.Authorize, handler.DeleteAPIKey)
+		usersRoutes.DELETE("/:id", auth.Authorize, handler.DeleteUser)
 	}
 	{
 		projectsRoutes.GET("/", handler.GetProjects)
 		projectsRoutes.GET("/:id", handler.GetProject)
-		projectsRoutes.POST("/", handler.PostProject)
-		projectsRoutes.PUT("/:id", handler.PutProject)
-		projectsRoutes.DELETE("/:id", handler.DeleteProject)
+		projectsRoutes.POST("/", auth.Authorize, handler.PostProject)
+		projectsRoutes.PUT("/:id", auth.Authorize, handler.PutProject)
+		projectsRoutes.DELETE("/:id", auth.Authorize, handler.DeleteProject)
 	}
 
 	port := fmt.Sprintf(":%s", os.Getenv("SERVER_PORT"))
@@ -0,0 +1,56 @@
+/*
+Package name : mailer
+File name : log.go
+Author : Antony Injila
+Description :
+	- Host a mailer that writes emails to a file or the log instead of sending them
+	- Used for local development and testing, e.g. to copy password reset links
+*/
+
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	errs "github.com/pkg/errors"
+)
+
+type logMailer struct {
+	mu sync.Mutex
+	// path is the file emails are appended to, they are logged when empty
+	path string
+}
+
+func NewLogMailer(c *config.AppConfig) ports.Mailer {
+	return &logMailer{
+		path: c.MailLogPath,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, mail *domain.Mail) error {
+	text := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", mail.To, mail.Subject, mail.Body)
+	if m.path == "" {
+		log.Printf("mail not sent\n%s", text)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errs.Wrap(err, "adapters.mailer.log.Send")
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text + "----\n"); err != nil {
+		return errs.Wrap(err, "adapters.mailer.log.Send")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func TestLogMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	mailer := NewLogMailer(&config.AppConfig{MailLogPath: path})
+
+	for _, to := range []string{"antony@gmail.com", "marco@gmail.com"} {
+		err := mailer.Send(context.Background(), &domain.Mail{
+			To:      to,
+			Subject: "Reset your password",
+			Body:    "http://localhost:3000/reset-password?token=abc",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(b)
+	for _, want := range []string{"To: antony@gmail.com", "To: marco@gmail.com", "Subject: Reset your password", "reset-password?token=abc"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("mail log %q does not contain %q", text, want)
+		}
+	}
+}
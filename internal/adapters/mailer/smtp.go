@@ -0,0 +1,101 @@
+/*
+Package name : mailer
+File name : smtp.go
+Author : Antony Injila
+Description :
+	- Host the SMTP implementation of the mailer port
+	- Upgrades the connection with STARTTLS when the server offers it
+*/
+
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	errs "github.com/pkg/errors"
+)
+
+type smtpMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(c *config.AppConfig) ports.Mailer {
+	return &smtpMailer{
+		host:     c.SMTPHost,
+		port:     c.SMTPPort,
+		username: c.SMTPUsername,
+		password: c.SMTPPassword,
+		from:     c.MailFrom,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, mail *domain.Mail) error {
+	// net/smtp has no context support, dial with one and let the deadline
+	// bound the whole conversation
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return errs.Wrap(err, "adapters.mailer.smtp.Send")
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return errs.Wrap(err, "adapters.mailer.smtp.Send")
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	if err := client.Rcpt(mail.To); err != nil {
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	if _, err := w.Write(message(m.from, mail)); err != nil {
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	if err := w.Close(); err != nil {
+		return errs.Wrap(err, "adapters.mailer.smtp.Send")
+	}
+	return client.Quit()
+}
+
+// message formats mail as a plain text email.
+func message(from string, mail *domain.Mail) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mail.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
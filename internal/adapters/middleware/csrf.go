@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFCookie holds the CSRF token set at login. It isn't HttpOnly, the
+	// frontend reads it and sends it back in CSRFHeader.
+	CSRFCookie = "csrf_token"
+	// CSRFHeader is where requests authenticated by cookie repeat the
+	// CSRF token. Other sites can make browsers send the cookies but can't
+	// read them to set the header.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CheckCSRF fails with ErrForbidden unless c is a safe request or its
+// CSRFHeader matches its CSRFCookie. Only requests authenticated by a
+// cookie need checking, other credentials aren't sent by browsers on their
+// own.
+func CheckCSRF(c *gin.Context) error {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	cookie, _ := c.Cookie(CSRFCookie)
+	header := c.GetHeader(CSRFHeader)
+	if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return domain.NewError(domain.ErrForbidden, "CSRF token is missing or invalid, send the %s cookie in the %s header", CSRFCookie, CSRFHeader)
+	}
+	return nil
+}
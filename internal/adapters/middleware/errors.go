@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// StatusCode returns the HTTP status code for an error returned by the
+// portfolio service.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// HandleErrors turns the last error handlers added with ctx.Error into a
+// problem details response, unless they already wrote a response.
+func HandleErrors(ctx *gin.Context) {
+	ctx.Next()
+
+	if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+		return
+	}
+	err := ctx.Errors.Last().Err
+	status := StatusCode(err)
+	// Storage clients don't always wrap the context error they gave up on
+	if status == http.StatusInternalServerError && errors.Is(ctx.Request.Context().Err(), context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: ctx.Request.URL.Path,
+	}
+	var domainErr *domain.Error
+	if status == http.StatusGatewayTimeout {
+		problem.Detail = "The request took too long to complete"
+	} else if status == http.StatusInternalServerError {
+		// Don't leak internal errors to clients
+		log.Printf("%s %s: %+v", ctx.Request.Method, ctx.Request.URL.Path, err)
+	} else if errors.As(err, &domainErr) {
+		problem.Detail = domainErr.Message
+		if domainErr.RetryAfter > 0 {
+			seconds := (domainErr.RetryAfter + time.Second - 1) / time.Second
+			ctx.Header("Retry-After", strconv.FormatInt(int64(seconds), 10))
+		}
+	} else {
+		problem.Detail = err.Error()
+	}
+
+	ctx.Header("Content-Type", "application/problem+json")
+	ctx.JSON(status, problem)
+}
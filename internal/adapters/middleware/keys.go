@@ -0,0 +1,440 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// JWKSMaxAge is how long verifiers may cache the JWKS document. A key is
+// published at least that long before it signs tokens.
+const JWKSMaxAge = 5 * time.Minute
+
+// SigningKey is a key access tokens are signed and verified with. The kid
+// header of a token names the key that signed it.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private signs tokens, it is nil for keys that only verify them. HMAC
+	// keys are the secret for both.
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+	// ExpiresAt is when a retired key stops verifying tokens, zero while
+	// the key is in use
+	ExpiresAt time.Time
+}
+
+// NewSigningKey returns the key for method with the private key, or with the
+// secret for HS256. Its id is derived from the key, so every instance of the
+// application names the same key the same way.
+func NewSigningKey(method jwt.SigningMethod, private crypto.PrivateKey) (*SigningKey, error) {
+	key := &SigningKey{Method: method, Private: private}
+	switch private := private.(type) {
+	case []byte:
+		if method != jwt.SigningMethodHS256 || len(private) == 0 {
+			return nil, fmt.Errorf("%s can't sign with a secret", method.Alg())
+		}
+		key.Public = private
+	case *rsa.PrivateKey:
+		if method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("%s can't sign with an RSA key", method.Alg())
+		}
+		key.Public = &private.PublicKey
+	case ed25519.PrivateKey:
+		if method != jwt.SigningMethodEdDSA {
+			return nil, fmt.Errorf("%s can't sign with an Ed25519 key", method.Alg())
+		}
+		key.Public = private.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key %T", private)
+	}
+	key.ID = keyID(key.Public)
+	return key, nil
+}
+
+// NewVerifyingKey returns a key that only verifies tokens, e.g. one that
+// was rotated out on another instance.
+func NewVerifyingKey(public crypto.PublicKey) (*SigningKey, error) {
+	key := &SigningKey{Public: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key %T", public)
+	}
+	key.ID = keyID(public)
+	return key, nil
+}
+
+// GenerateSigningKey returns a new random key for method.
+func GenerateSigningKey(method jwt.SigningMethod) (*SigningKey, error) {
+	var private crypto.PrivateKey
+	var err error
+	switch method {
+	case jwt.SigningMethodHS256:
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		private = secret
+	case jwt.SigningMethodRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing method %s", method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(method, private)
+}
+
+// LoadKeyManager returns the keys configured with JWT_ALGORITHM and the PEM
+// files of JWT_PRIVATE_KEY and JWT_RETIRED_KEYS. Retired keys, and the
+// SECRET_KEY tokens were signed with before switching to an asymmetric
+// algorithm, verify tokens for the overlap period after startup.
+func LoadKeyManager(config config.AppConfig) (*KeyManager, error) {
+	if config.JWTKeyOverlap < AccessTokenTTL {
+		return nil, fmt.Errorf("JWT_KEY_OVERLAP %s is shorter than the %s tokens are valid", config.JWTKeyOverlap, AccessTokenTTL)
+	}
+	if config.JWTRotation > 0 && config.JWTRotation < JWKSMaxAge {
+		return nil, fmt.Errorf("JWT_ROTATION_INTERVAL %s is shorter than the %s verifiers cache the published keys", config.JWTRotation, JWKSMaxAge)
+	}
+	method := jwt.GetSigningMethod(config.JWTAlgorithm)
+	switch method {
+	case jwt.SigningMethodHS256, jwt.SigningMethodRS256, jwt.SigningMethodEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", config.JWTAlgorithm)
+	}
+
+	var current *SigningKey
+	var err error
+	switch {
+	case method == jwt.SigningMethodHS256 && config.SecretKey != "":
+		current, err = NewSigningKey(method, []byte(config.SecretKey))
+	case method != jwt.SigningMethodHS256 && config.JWTPrivateKey != "":
+		current, err = readSigningKey(method, config.JWTPrivateKey)
+	default:
+		log.Printf("No %s signing key configured, tokens are signed with a generated key and invalid after a restart", method.Alg())
+		current, err = GenerateSigningKey(method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(config.JWTKeyOverlap)
+	var retired []*SigningKey
+	if method != jwt.SigningMethodHS256 && config.SecretKey != "" {
+		legacy, err := NewSigningKey(jwt.SigningMethodHS256, []byte(config.SecretKey))
+		if err != nil {
+			return nil, err
+		}
+		retired = append(retired, legacy)
+	}
+	for _, path := range config.JWTRetiredKeys {
+		key, err := readVerifyingKey(path)
+		if err != nil {
+			return nil, err
+		}
+		retired = append(retired, key)
+	}
+	for _, key := range retired {
+		key.ExpiresAt = expiresAt
+	}
+	return NewKeyManager(current, retired...), nil
+}
+
+func readSigningKey(method jwt.SigningMethod, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var private crypto.PrivateKey
+	switch method {
+	case jwt.SigningMethodRS256:
+		private, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+	case jwt.SigningMethodEdDSA:
+		private, err = jwt.ParseEdPrivateKeyFromPEM(data)
+	default:
+		return nil, fmt.Errorf("unsupported signing method %s", method.Alg())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s key %s: %w", method.Alg(), path, err)
+	}
+	return NewSigningKey(method, private)
+}
+
+// readVerifyingKey reads the public key, or the public half of the private
+// key, of a PEM file.
+func readVerifyingKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return NewVerifyingKey(public)
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return NewVerifyingKey(public)
+	}
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return NewVerifyingKey(&private.PublicKey)
+	}
+	if private, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		return NewVerifyingKey(private.(crypto.Signer).Public())
+	}
+	return nil, fmt.Errorf("no RSA or Ed25519 key in %s", path)
+}
+
+// keyID returns the RFC 7638 thumbprint of a public key. HMAC secrets are
+// never published, their id is a hash that doesn't give the secret away.
+func keyID(public crypto.PublicKey) string {
+	var members string
+	switch public := public.(type) {
+	case []byte:
+		sum := sha256.Sum256(append([]byte("hmac-key-id:"), public...))
+		return base64.RawURLEncoding.EncodeToString(sum[:12])
+	default:
+		jwk := newJWK(&SigningKey{Public: public})
+		if jwk.Kty == "RSA" {
+			members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+		} else {
+			members = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, jwk.Crv, jwk.X)
+		}
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeyManager signs access tokens with its current key and verifies them with
+// any of its keys. Rotated keys keep verifying tokens for an overlap period,
+// so tokens signed just before a rotation stay valid until they expire.
+type KeyManager struct {
+	mu      sync.RWMutex
+	current *SigningKey
+	keys    []*SigningKey
+}
+
+// NewKeyManager signs with current and also verifies tokens signed with the
+// retired keys.
+func NewKeyManager(current *SigningKey, retired ...*SigningKey) *KeyManager {
+	keys := []*SigningKey{current}
+	seen := map[string]bool{current.ID: true}
+	for _, key := range retired {
+		if !seen[key.ID] {
+			keys = append(keys, key)
+			seen[key.ID] = true
+		}
+	}
+	return &KeyManager{
+		current: current,
+		keys:    keys,
+	}
+}
+
+// NewHMACKeyManager signs and verifies HS256 tokens with secret.
+func NewHMACKeyManager(secret []byte) (*KeyManager, error) {
+	key, err := NewSigningKey(jwt.SigningMethodHS256, secret)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyManager(key), nil
+}
+
+// Sign returns a token with claims signed with the current key.
+func (m *KeyManager) Sign(claims jwt.MapClaims) (string, error) {
+	m.mu.RLock()
+	key := m.current
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc returns the key to verify token with, for jwt.Parse. Tokens issued
+// before keys had ids are verified with the HMAC key, if there is one.
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := m.lookup(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	// The algorithm comes from the token, it has to be the one of the key
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+func (m *KeyManager) lookup(kid string) *SigningKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range m.keys {
+		if !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(now) {
+			continue
+		}
+		if key.ID == kid || kid == "" && key.Method == jwt.SigningMethodHS256 {
+			return key
+		}
+	}
+	return nil
+}
+
+// Publish lists next in the JWKS document without signing with it yet, so
+// verifiers that cache the document know it by the time Rotate makes it
+// the current key.
+func (m *KeyManager) Publish(next *SigningKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.keys {
+		if key.ID == next.ID {
+			return
+		}
+	}
+	m.keys = append(m.keys, next)
+}
+
+// Rotate signs new tokens with next. The current key verifies tokens for
+// overlap longer, which should be at least AccessTokenTTL. Keys that were
+// not published with Publish at least JWKSMaxAge before are unknown to
+// verifiers with a cached JWKS document for a while.
+func (m *KeyManager) Rotate(next *SigningKey, overlap time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.current.ExpiresAt = now.Add(overlap)
+	keys := []*SigningKey{next}
+	for _, key := range m.keys {
+		if key.ID == next.ID {
+			continue
+		}
+		if key.ExpiresAt.IsZero() || key.ExpiresAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	m.current, m.keys = next, keys
+}
+
+// RotateEvery replaces the current key with a new random one every interval
+// until ctx is done. Every key is published one interval before it signs
+// tokens, so interval has to be at least JWKSMaxAge. Generated keys are only
+// known to this instance, so instances behind a load balancer share keys
+// from files instead.
+func (m *KeyManager) RotateEvery(ctx context.Context, interval, overlap time.Duration) {
+	m.mu.RLock()
+	method := m.current.Method
+	m.mu.RUnlock()
+
+	next, err := GenerateSigningKey(method)
+	if err != nil {
+		log.Printf("Generating the next token signing key: %v", err)
+	} else {
+		m.Publish(next)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if next != nil {
+				m.Rotate(next, overlap)
+			}
+			if next, err = GenerateSigningKey(method); err != nil {
+				log.Printf("Generating the next token signing key: %v", err)
+				continue
+			}
+			m.Publish(next)
+		}
+	}
+}
+
+// JWK is an RFC 7517 JSON web key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func newJWK(key *SigningKey) JWK {
+	jwk := JWK{Kid: key.ID, Use: "sig"}
+	if key.Method != nil {
+		jwk.Alg = key.Method.Alg()
+	}
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// JWKS returns the public keys tokens can be verified with. HMAC keys are
+// secret and never listed.
+func (m *KeyManager) JWKS() []JWK {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	keys := []JWK{}
+	for _, key := range m.keys {
+		if key.Method == jwt.SigningMethodHS256 {
+			continue
+		}
+		if !key.ExpiresAt.IsZero() && !key.ExpiresAt.After(now) {
+			continue
+		}
+		keys = append(keys, newJWK(key))
+	}
+	return keys
+}
+
+// ServeJWKS responds with the JWKS document of /.well-known/jwks.json.
+func (m *KeyManager) ServeJWKS(c *gin.Context) {
+	body, err := json.Marshal(gin.H{"keys": m.JWKS()})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	// Verifiers cache the keys, RotateEvery publishes every key at least
+	// this long before it signs tokens
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(JWKSMaxAge.Seconds())))
+	c.Data(http.StatusOK, "application/json", body)
+}
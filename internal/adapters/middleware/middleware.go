@@ -1,33 +1,38 @@
 package middleware
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
-	"os"
+	"strings"
 	"time"
 
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
 	"github.com/AntonyIS/portfolio-be/internal/core/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
+// AccessTokenTTL is how long a token minted by GenerateToken is valid.
+// Clients exchange their refresh token for a new one before it expires.
+const AccessTokenTTL = 30 * time.Minute
+
 type middleware struct {
-	svc *services.PortfolioService
+	svc  *services.PortfolioService
+	keys *KeyManager
 }
 
-func NewMiddleware(svc *services.PortfolioService) *middleware {
+func NewMiddleware(svc *services.PortfolioService, keys *KeyManager) *middleware {
 	return &middleware{
-		svc: svc,
+		svc:  svc,
+		keys: keys,
 	}
 }
 
-func (m middleware) GenerateToken(id string) (string, error) {
-	key := []byte(os.Getenv("SECRET_KEY"))
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	user, err := m.svc.ReadUser(id)
+func (m middleware) GenerateToken(ctx context.Context, id string) (string, error) {
+	claims := jwt.MapClaims{}
+	user, err := m.svc.ReadUser(ctx, id)
 	if err != nil {
 		return "", err
 	}
@@ -35,9 +40,12 @@ func (m middleware) GenerateToken(id string) (string, error) {
 	claims["user_id"] = user.Id
 	claims["firstname"] = user.FirstName
 	claims["lastname"] = user.LastName
-	claims["exp"] = time.Now().Add(time.Minute * 30).Unix()
+	claims["role"] = string(user.Role)
+	// The token id lets Logout revoke this token only
+	claims["jti"] = uuid.New().String()
+	claims["exp"] = time.Now().Add(AccessTokenTTL).Unix()
 
-	tokenString, err := token.SignedString(key)
+	tokenString, err := m.keys.Sign(claims)
 
 	if err != nil {
 		return "", err
@@ -45,26 +53,73 @@ func (m middleware) GenerateToken(id string) (string, error) {
 	return tokenString, nil
 }
 
+// Authorize rejects requests without a valid token and stores the claims of
+// the token on the context for the handlers. The token is read from an
+// Authorization: Bearer header, the older token header, or the cookie set at
+// login, in which case unsafe requests must pass CheckCSRF. Scripts send an
+// API key in the Authorization header instead.
 func (m middleware) Authorize(c *gin.Context) {
-	tokenString := c.GetHeader("token")
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["sub"])
+	header := c.GetHeader("Authorization")
+	if scheme, credentials, _ := strings.Cut(header, " "); strings.EqualFold(scheme, "ApiKey") {
+		m.authorizeAPIKey(c, strings.TrimSpace(credentials))
+		return
+	} else if strings.EqualFold(scheme, "Bearer") {
+		header = strings.TrimSpace(credentials)
+	} else if header != "" {
+		c.Error(domain.NewError(domain.ErrUnauthorized, "Authorization scheme %q is not supported, use Bearer or ApiKey", scheme))
+		c.Abort()
+		return
+	}
+	tokenString := header
+	if tokenString == "" {
+		tokenString = c.GetHeader("token")
+	}
+	if tokenString == "" {
+		tokenString, _ = c.Cookie("token")
+		if tokenString != "" {
+			if err := CheckCSRF(c); err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
 		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
+	}
+	if tokenString == "" {
+		c.Error(domain.NewError(domain.ErrUnauthorized, "Authorization token is missing"))
+		c.Abort()
+		return
+	}
+
+	token, err := jwt.Parse(tokenString, m.keys.Keyfunc)
 
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
+		c.Error(domain.NewError(domain.ErrUnauthorized, err.Error()))
+		c.Abort()
 		return
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
+		// Tokens without an expiry are not accepted
+		if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+			c.Error(domain.NewError(domain.ErrUnauthorized, "Token is expired"))
+			c.Abort()
+			return
+		}
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			c.Error(domain.NewError(domain.ErrUnauthorized, "Token has no id, log in again"))
+			c.Abort()
+			return
+		}
+		revoked, err := m.svc.IsTokenRevoked(c.Request.Context(), jti)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.Error(domain.NewError(domain.ErrUnauthorized, "Token has been revoked"))
+			c.Abort()
 			return
 		}
 		email := fmt.Sprintf("%s", claims["email"])
@@ -75,11 +130,35 @@ func (m middleware) Authorize(c *gin.Context) {
 		c.Set("user_id", user_id)
 		c.Set("firstname", firstname)
 		c.Set("lastname", lastname)
+		if role, ok := claims["role"].(string); ok {
+			c.Set("role", role)
+		}
+		c.Set("jti", jti)
+		exp, _ := claims["exp"].(float64)
+		c.Set("exp", time.Unix(int64(exp), 0))
 		c.Next()
 	} else {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"error": errors.New("Request not authorized"),
-		})
+		c.Error(domain.NewError(domain.ErrUnauthorized, "Request not authorized"))
+		c.Abort()
+		return
+	}
+}
+
+// authorizeAPIKey stores the user of an API key on the context like the
+// claims of a token, and the scopes the key is limited to.
+func (m middleware) authorizeAPIKey(c *gin.Context, key string) {
+	user, apiKey, err := m.svc.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		c.Error(err)
+		c.Abort()
 		return
 	}
+	c.Set("email", user.Email)
+	c.Set("user_id", user.Id)
+	c.Set("firstname", user.FirstName)
+	c.Set("lastname", user.LastName)
+	c.Set("role", string(user.Role))
+	c.Set("api_key", apiKey.Id)
+	c.Set("scopes", apiKey.Scopes)
+	c.Next()
 }
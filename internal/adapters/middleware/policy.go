@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission is something a request may ask to do.
+type Permission string
+
+const (
+	ListUsers      Permission = "users:list"
+	UpdateUser     Permission = "users:update"
+	DeleteUser     Permission = "users:delete"
+	ManageRoles    Permission = "users:roles"
+	WriteProjects  Permission = "projects:write"
+	DeleteProjects Permission = "projects:delete"
+	// ManageAPIKeys is no scope of domain.APIKeyScopes, API keys can't
+	// create more keys
+	ManageAPIKeys Permission = "users:api_keys"
+)
+
+// scope is whose data a role may apply a permission to.
+type scope int
+
+const (
+	scopeOwn scope = iota + 1
+	scopeAny
+)
+
+// policy lists the permissions of every role. Permissions a role does not
+// list are denied.
+var policy = map[domain.Role]map[Permission]scope{
+	domain.RoleAdmin: {
+		ListUsers:      scopeAny,
+		UpdateUser:     scopeOwn,
+		DeleteUser:     scopeAny,
+		ManageRoles:    scopeAny,
+		WriteProjects:  scopeOwn,
+		DeleteProjects: scopeAny,
+		ManageAPIKeys:  scopeOwn,
+	},
+	domain.RoleOwner: {
+		UpdateUser:     scopeOwn,
+		DeleteUser:     scopeOwn,
+		WriteProjects:  scopeOwn,
+		DeleteProjects: scopeOwn,
+		ManageAPIKeys:  scopeOwn,
+	},
+	domain.RoleViewer: {
+		UpdateUser:    scopeOwn,
+		DeleteUser:    scopeOwn,
+		ManageAPIKeys: scopeOwn,
+	},
+}
+
+// Allow returns an error unless the user authenticated by Authorize has
+// permission on data owned by ownerID.
+func Allow(c *gin.Context, permission Permission, ownerID string) error {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return domain.NewError(domain.ErrUnauthorized, "Request not authorized")
+	}
+	if err := checkScope(c, permission); err != nil {
+		return err
+	}
+	switch policy[role(c)][permission] {
+	case scopeAny:
+		return nil
+	case scopeOwn:
+		if userID == ownerID {
+			return nil
+		}
+		return domain.NewError(domain.ErrForbidden, "You can only change your own data")
+	default:
+		return domain.NewError(domain.ErrForbidden, "Your role does not allow this request")
+	}
+}
+
+// Require guards routes that don't act on the data of a single user, only
+// roles allowed to apply permission to anyone's data get through.
+func Require(permission Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("user_id"); !ok {
+			c.Error(domain.NewError(domain.ErrUnauthorized, "Request not authorized"))
+			c.Abort()
+			return
+		}
+		if err := checkScope(c, permission); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if policy[role(c)][permission] != scopeAny {
+			c.Error(domain.NewError(domain.ErrForbidden, "Your role does not allow this request"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkScope returns an error for requests made with an API key that isn't
+// given permission. Keys are also limited by the role of their user.
+func checkScope(c *gin.Context, permission Permission) error {
+	scopes, ok := c.Get("scopes")
+	if !ok {
+		return nil
+	}
+	for _, scope := range scopes.([]string) {
+		if scope == string(permission) {
+			return nil
+		}
+	}
+	return domain.NewError(domain.ErrForbidden, "This API key lacks the %s scope", permission)
+}
+
+// role returns the role in the token of the request. Tokens minted before
+// users had roles belong to owners.
+func role(c *gin.Context) domain.Role {
+	role := domain.Role(c.GetString("role"))
+	if role == "" {
+		return domain.RoleOwner
+	}
+	return role
+}
@@ -0,0 +1,90 @@
+/*
+Package name : oauth
+File name : github.go
+Author : Antony Injila
+Description :
+	- Host the GitHub identity provider, GitHub supports OAuth2 but not OpenID Connect
+	- The email is the primary email of the account, if GitHub verified it
+*/
+
+package oauth
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	errs "github.com/pkg/errors"
+)
+
+type githubProvider struct {
+	client
+	apiURL string
+}
+
+func NewGitHubProvider(clientID, clientSecret, redirectURL string) ports.IdentityProvider {
+	return newGitHubProvider(clientID, clientSecret, redirectURL, "https://github.com", "https://api.github.com")
+}
+
+func newGitHubProvider(clientID, clientSecret, redirectURL, webURL, apiURL string) *githubProvider {
+	return &githubProvider{
+		client: client{
+			name:         "github",
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			redirectURL:  redirectURL,
+			authURL:      webURL + "/login/oauth/authorize",
+			tokenURL:     webURL + "/login/oauth/access_token",
+			scopes:       []string{"read:user", "user:email"},
+			http:         httpClient,
+		},
+		apiURL: apiURL,
+	}
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, verifier string) (*domain.ExternalIdentity, error) {
+	accessToken, err := p.exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, p.apiURL+"/user", accessToken, &user); err != nil {
+		return nil, errs.Wrap(err, "adapters.oauth.github.Exchange")
+	}
+	// The email on the profile is the public one, which isn't always set
+	// and may not be verified
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, p.apiURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, errs.Wrap(err, "adapters.oauth.github.Exchange")
+	}
+
+	identity := &domain.ExternalIdentity{
+		Provider:  p.name,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		FirstName: user.Login,
+	}
+	if user.Name != "" {
+		names := strings.SplitN(user.Name, " ", 2)
+		identity.FirstName = names[0]
+		if len(names) == 2 {
+			identity.LastName = names[1]
+		}
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	return identity, nil
+}
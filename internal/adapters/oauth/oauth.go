@@ -0,0 +1,160 @@
+/*
+Package name : oauth
+File name : oauth.go
+Author : Antony Injila
+Description :
+	- Host the OAuth2 authorization code flow with PKCE shared by the identity providers
+	- Build the identity providers enabled in the configuration
+*/
+
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	errs "github.com/pkg/errors"
+)
+
+// GoogleIssuer is the OpenID Connect issuer of Google accounts.
+const GoogleIssuer = "https://accounts.google.com"
+
+// httpClient talks to the providers. A provider that stops answering fails
+// the login instead of holding the request, and discovery at startup.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewIdentityProviders returns the providers with a client id configured,
+// by the name used in their login URLs.
+func NewIdentityProviders(ctx context.Context, c *config.AppConfig) (map[string]ports.IdentityProvider, error) {
+	providers := map[string]ports.IdentityProvider{}
+	callback := func(name string) string {
+		return strings.TrimSuffix(c.OAuthCallbackURL, "/") + "/" + name + "/callback"
+	}
+	if c.GitHubClientID != "" {
+		providers["github"] = NewGitHubProvider(c.GitHubClientID, c.GitHubClientSecret, callback("github"))
+	}
+	if c.GoogleClientID != "" {
+		google, err := NewOIDCProvider(ctx, "google", GoogleIssuer, c.GoogleClientID, c.GoogleClientSecret, callback("google"))
+		if err != nil {
+			return nil, err
+		}
+		providers["google"] = google
+	}
+	if c.OIDCClientID != "" {
+		oidc, err := NewOIDCProvider(ctx, "oidc", c.OIDCIssuer, c.OIDCClientID, c.OIDCClientSecret, callback("oidc"))
+		if err != nil {
+			return nil, err
+		}
+		providers["oidc"] = oidc
+	}
+	return providers, nil
+}
+
+// client runs the authorization code flow against the endpoints of one
+// provider.
+type client struct {
+	name         string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	authURL      string
+	tokenURL     string
+	scopes       []string
+	http         *http.Client
+}
+
+func (c *client) AuthCodeURL(state, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {c.redirectURL},
+		"scope":                 {strings.Join(c.scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(c.authURL, "?") {
+		separator = "&"
+	}
+	return c.authURL + separator + query.Encode()
+}
+
+// exchange trades the code for an access token. Codes the provider rejects
+// fail with ErrUnauthorized.
+func (c *client) exchange(ctx context.Context, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errs.Wrap(err, "adapters.oauth.exchange")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return "", errs.Wrap(err, "adapters.oauth.exchange")
+	}
+	defer res.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&token); err != nil {
+		return "", errs.Wrap(err, "adapters.oauth.exchange")
+	}
+	// GitHub reports errors with 200 OK
+	if token.Error != "" || res.StatusCode != http.StatusOK || token.AccessToken == "" {
+		reason := token.ErrorDescription
+		if reason == "" {
+			reason = token.Error
+		}
+		if reason == "" {
+			reason = res.Status
+		}
+		return "", domain.NewError(domain.ErrUnauthorized, "%s login failed: %s", c.name, reason)
+	}
+	return token.AccessToken, nil
+}
+
+// get reads the JSON response to an API request made with accessToken, if
+// there is one.
+func (c *client) get(ctx context.Context, url, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,110 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/AntonyIS/portfolio-be/internal/adapters/oauth/oauthtest"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+)
+
+// authorize logs in at the provider and returns the code it redirected back
+// with.
+func authorize(t *testing.T, provider ports.IdentityProvider, verifier string) string {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(provider.AuthCodeURL("some-state", verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize responded %s, want a redirect", res.Status)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := location.Query().Get("state"); state != "some-state" {
+		t.Errorf("redirected back with state %q, want some-state", state)
+	}
+	return location.Query().Get("code")
+}
+
+func TestOIDCProvider(t *testing.T) {
+	ctx := context.Background()
+	idp := oauthtest.NewServer(oauthtest.User{
+		Subject:       "248289761001",
+		Email:         "antony@gmail.com",
+		EmailVerified: true,
+		GivenName:     "Antony",
+		FamilyName:    "Injila",
+	})
+	defer idp.Close()
+
+	provider, err := NewOIDCProvider(ctx, "google", idp.URL, "client-id", "client-secret", "http://localhost:8081/api/v1/login/google/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := authorize(t, provider, "the-verifier")
+	identity, err := provider.Exchange(ctx, code, "the-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := domain.ExternalIdentity{Provider: "google", Subject: "248289761001", Email: "antony@gmail.com", EmailVerified: true, FirstName: "Antony", LastName: "Injila"}
+	if *identity != want {
+		t.Errorf("identity is %+v, want %+v", identity, want)
+	}
+
+	// Codes are used once, and only with the verifier they were issued for
+	if _, err := provider.Exchange(ctx, code, "the-verifier"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("reusing a code returned %v, want ErrUnauthorized", err)
+	}
+	code = authorize(t, provider, "the-verifier")
+	if _, err := provider.Exchange(ctx, code, "another-verifier"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("exchanging a code with another verifier returned %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestOIDCProviderWithWrongIssuer(t *testing.T) {
+	idp := oauthtest.NewServer(oauthtest.User{})
+	defer idp.Close()
+
+	if _, err := NewOIDCProvider(context.Background(), "oidc", idp.URL+"/other", "client-id", "client-secret", "http://localhost"); err == nil {
+		t.Error("created a provider for an issuer without a discovery document")
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	ctx := context.Background()
+	idp := oauthtest.NewServer(oauthtest.User{
+		Subject:       "583231",
+		Login:         "octocat",
+		Email:         "octocat@github.com",
+		EmailVerified: true,
+		GivenName:     "The",
+		FamilyName:    "Octocat",
+	})
+	defer idp.Close()
+
+	provider := newGitHubProvider("client-id", "client-secret", "http://localhost:8081/api/v1/login/github/callback", idp.URL, idp.URL)
+	code := authorize(t, provider, "the-verifier")
+	identity, err := provider.Exchange(ctx, code, "the-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := domain.ExternalIdentity{Provider: "github", Subject: "583231", Email: "octocat@github.com", EmailVerified: true, FirstName: "The", LastName: "Octocat"}
+	if *identity != want {
+		t.Errorf("identity is %+v, want %+v", identity, want)
+	}
+
+	if _, err := provider.Exchange(ctx, "not-a-code", "the-verifier"); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("exchanging an unknown code returned %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Package name : oauthtest
+File name : oauthtest.go
+Author : Antony Injila
+Description :
+	- Host a mock identity provider to test logins without GitHub or Google
+	- Serves OpenID Connect discovery, userinfo and the GitHub user API
+	- Logs in User without asking and checks the PKCE code verifier
+*/
+
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// User is the account that logs in at the provider.
+type User struct {
+	Subject       string
+	Login         string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// Server is the mock provider. Its URL is the OpenID Connect issuer, and the
+// web and API URL of GitHub.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	user   User
+	codes  map[string]grant
+	tokens map[string]User
+}
+
+type grant struct {
+	user        User
+	challenge   string
+	redirectURL string
+}
+
+// NewServer starts a provider that logs in user.
+func NewServer(user User) *Server {
+	s := &Server{user: user, codes: map[string]grant{}, tokens: map[string]User{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userInfo)
+	mux.HandleFunc("/login/oauth/authorize", s.authorize)
+	mux.HandleFunc("/login/oauth/access_token", s.token)
+	mux.HandleFunc("/user", s.githubUser)
+	mux.HandleFunc("/user/emails", s.githubEmails)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetUser changes who logs in next.
+func (s *Server) SetUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+	})
+}
+
+// authorize redirects back with a code right away, as if the user logged in
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = grant{user: s.user, challenge: query.Get("code_challenge"), redirectURL: query.Get("redirect_uri")}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := r.PostFormValue("code")
+	grant, ok := s.codes[code]
+	// Codes are used once
+	delete(s.codes, code)
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge || r.PostFormValue("redirect_uri") != grant.redirectURL {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	token := randomString()
+	s.tokens[token] = grant.user
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": token, "token_type": "Bearer"})
+}
+
+func (s *Server) bearer(r *http.Request) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	return user, ok
+}
+
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.bearer(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":            user.Subject,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"given_name":     user.GivenName,
+		"family_name":    user.FamilyName,
+	})
+}
+
+func (s *Server) githubUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.bearer(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":    json.Number(user.Subject),
+		"login": user.Login,
+		"name":  strings.TrimSpace(user.GivenName + " " + user.FamilyName),
+	})
+}
+
+func (s *Server) githubEmails(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.bearer(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, []map[string]interface{}{
+		{"email": "noreply@users.github.com", "primary": false, "verified": true},
+		{"email": user.Email, "primary": true, "verified": user.EmailVerified},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+/*
+Package name : oauth
+File name : oidc.go
+Author : Antony Injila
+Description :
+	- Host the OpenID Connect identity provider, used for Google and any other issuer
+	- Endpoints are read from the discovery document of the issuer
+	- The identity is read from the userinfo endpoint with the access token
+*/
+
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	errs "github.com/pkg/errors"
+)
+
+type oidcProvider struct {
+	client
+	userInfoURL string
+}
+
+// NewOIDCProvider returns the provider of issuer, with the endpoints its
+// discovery document lists.
+func NewOIDCProvider(ctx context.Context, name, issuer, clientID, clientSecret, redirectURL string) (ports.IdentityProvider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	c := client{http: httpClient}
+	if err := c.get(ctx, issuer+"/.well-known/openid-configuration", "", &discovery); err != nil {
+		return nil, errs.Wrap(err, "adapters.oauth.NewOIDCProvider")
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("issuer %s has a discovery document for %s", issuer, discovery.Issuer)
+	}
+	return &oidcProvider{
+		client: client{
+			name:         name,
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			redirectURL:  redirectURL,
+			authURL:      discovery.AuthorizationEndpoint,
+			tokenURL:     discovery.TokenEndpoint,
+			scopes:       []string{"openid", "email", "profile"},
+			http:         httpClient,
+		},
+		userInfoURL: discovery.UserInfoEndpoint,
+	}, nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier string) (*domain.ExternalIdentity, error) {
+	accessToken, err := p.exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	var claims struct {
+		Subject    string `json:"sub"`
+		Email      string `json:"email"`
+		GivenName  string `json:"given_name"`
+		FamilyName string `json:"family_name"`
+		// Some providers send the boolean as a string
+		EmailVerified interface{} `json:"email_verified"`
+	}
+	if err := p.get(ctx, p.userInfoURL, accessToken, &claims); err != nil {
+		return nil, errs.Wrap(err, "adapters.oauth.oidc.Exchange")
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("adapters.oauth.oidc.Exchange: %s userinfo has no subject", p.name)
+	}
+	return &domain.ExternalIdentity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}, nil
+}
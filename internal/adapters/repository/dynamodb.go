@@ -9,8 +9,10 @@ Description :
 package repository
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/AntonyIS/portfolio-be/config"
 	"github.com/AntonyIS/portfolio-be/internal/core/domain"
@@ -24,110 +26,229 @@ import (
 	errs "github.com/pkg/errors"
 )
 
-var (
-	internalServerError = config.ErrInternalServer.Error()
-	itemNotFound        = config.ErrNotFound.Error()
-	invalidItem         = config.ErrInvalidItem.Error()
-)
-
 type dynamoDbClient struct {
 	client            *dynamodb.DynamoDB
 	usersTableName    string
 	projectsTableName string
+	tokensTableName   string
 }
 
 func NewDynamoDBRepository(c *config.AppConfig) ports.PortfolioRepository {
+	return newDynamoDBClient(c)
+}
+
+func newDynamoDBClient(c *config.AppConfig) *dynamoDbClient {
 	creds := credentials.NewStaticCredentials(
 		c.AWSAccessKeyID,
 		c.AWSAccessSecretKey,
 		"")
 
-	sess := session.Must(session.NewSession(&aws.Config{
+	awsConfig := &aws.Config{
 		Region:      aws.String(c.AWSDefaultRegion),
 		Credentials: creds,
-	}))
+	}
+	// Point the client at DynamoDB Local or LocalStack
+	if c.DynamoDBEndpoint != "" {
+		awsConfig.Endpoint = aws.String(c.DynamoDBEndpoint)
+	}
+
+	sess := session.Must(session.NewSession(awsConfig))
 
 	return &dynamoDbClient{
 		client:            dynamodb.New(sess),
 		usersTableName:    c.UsersTable,
 		projectsTableName: c.ProjectTable,
+		tokensTableName:   c.TokenTable,
 	}
 }
 
-func (db *dynamoDbClient) CreateUser(user *domain.User) (*domain.User, error) {
+func (db *dynamoDbClient) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
 	entityParsed, err := dynamodbattribute.MarshalMap(user)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), internalServerError)
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.CreateUser")
+	}
+
+	// Write the user together with the lock on its email, the transaction
+	// fails if another user holds the lock
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					Item:      entityParsed,
+					TableName: aws.String(db.usersTableName),
+				},
+			},
+			{
+				Put: db.putEmailLock(user),
+			},
+		},
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      entityParsed,
-		TableName: aws.String(db.usersTableName),
+	_, err = db.client.TransactWriteItemsWithContext(ctx, input)
+	if isConditionalCheckFailed(err) {
+		return nil, domain.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.CreateUser")
 	}
 
-	_, err = db.client.PutItem(input)
+	return user, nil
+}
 
+func (db *dynamoDbClient) ReadUser(ctx context.Context, id string) (*domain.User, error) {
+	item, err := db.getUserItem(ctx, id, false)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.CreateUser")
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUser")
+	}
+	if item == nil {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", id)
+	}
+	user, err := unmarshalUser(item)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUser")
 	}
 
 	return user, nil
 }
 
-func (db *dynamoDbClient) ReadUser(id string) (*domain.User, error) {
-	result, err := db.client.GetItem(&dynamodb.GetItemInput{
+// getUserItem returns the item of the user with id as it is stored, or nil
+// if there is no such user. Email locks are not users.
+func (db *dynamoDbClient) getUserItem(ctx context.Context, id string, consistent bool) (map[string]*dynamodb.AttributeValue, error) {
+	if isEmailLockID(id) {
+		return nil, nil
+	}
+	result, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(db.usersTableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: aws.String(id),
 			},
 		},
+		ConsistentRead: aws.Bool(consistent),
 	})
 	if err != nil {
-		return &domain.User{}, err
-	}
-	if result.Item == nil {
-		msg := fmt.Sprintf("User with id [ %s ] not found", id)
-		return &domain.User{}, errors.New(msg)
+		return nil, err
 	}
+	return result.Item, nil
+}
+
+// Certifications used to be stored in the certification attribute, with
+// their description in decription. Users written before the rename are read
+// from the old names until their certifications change.
+const legacyCertificationsAttribute = "certification"
+
+// unmarshalUser returns the user stored in item.
+func unmarshalUser(item map[string]*dynamodb.AttributeValue) (*domain.User, error) {
 	var user domain.User
-	err = dynamodbattribute.UnmarshalMap(result.Item, &user)
+	if err := dynamodbattribute.UnmarshalMap(item, &user); err != nil {
+		return nil, err
+	}
+	legacy, ok := item[legacyCertificationsAttribute]
+	if _, migrated := item["certifications"]; migrated || !ok {
+		return &user, nil
+	}
+	if err := dynamodbattribute.Unmarshal(legacy, &user.Certifications); err != nil {
+		return nil, err
+	}
+	var descriptions []struct {
+		Decription string `json:"decription"`
+	}
+	if err := dynamodbattribute.Unmarshal(legacy, &descriptions); err != nil {
+		return nil, err
+	}
+	for i, certification := range user.Certifications {
+		if certification.Description == "" {
+			certification.Description = descriptions[i].Decription
+		}
+	}
+	return &user, nil
+}
+
+func (db *dynamoDbClient) ReadUserWithEmail(ctx context.Context, email string) (*domain.User, error) {
+	keyCond := expression.Key("email").Equal(expression.Value(email))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
 	if err != nil {
-		return &domain.User{}, err
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUserWithEmail")
 	}
 
-	return &user, nil
+	result, err := db.client.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.usersTableName),
+		IndexName:                 aws.String(usersEmailIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int64(1),
+	})
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUserWithEmail")
+	}
+	if len(result.Items) == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "user with email [ %s ] not found", email)
+	}
+
+	user, err := unmarshalUser(result.Items[0])
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUserWithEmail")
+	}
+	return user, nil
 }
 
-func (db *dynamoDbClient) ReadUserWithEmail(email string) (*domain.User, error) {
-	users, err := db.ReadUsers()
+func (db *dynamoDbClient) ReadUserWithIdentity(ctx context.Context, key string) (*domain.User, error) {
+	// Lists can't be indexed, so the users are scanned. Logins with an
+	// identity provider are rare enough for that
+	filt := expression.Name("identities").Contains(key)
+	expr, err := expression.NewBuilder().WithFilter(filt).Build()
 	if err != nil {
-		return nil, err
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUserWithIdentity")
 	}
-	for _, user := range users {
-		if user.Email == email {
-			return user, nil
-		}
+	items, _, err := db.scanPage(ctx, &dynamodb.ScanInput{
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		TableName:                 aws.String(db.usersTableName),
+	}, 0, "")
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUserWithIdentity")
 	}
-	return nil, errors.New("user with id not found!")
+	if len(items) == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "user with identity [ %s ] not found", key)
+	}
+
+	user, err := unmarshalUser(items[0])
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadUserWithIdentity")
+	}
+	return user, nil
 }
 
-func (db *dynamoDbClient) ReadUsers() ([]*domain.User, error) {
+func (db *dynamoDbClient) ReadUsers(ctx context.Context, limit int, cursor string) ([]*domain.User, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
 	users := []*domain.User{}
-	filt := expression.Name("Id").AttributeNotExists()
+	// Skip the email lock items
+	filt := expression.Name("email_owner").AttributeNotExists()
+	// Listings don't load passwords and two-factor secrets
 	proj := expression.NamesList(
 		expression.Name("id"),
 		expression.Name("firstname"),
 		expression.Name("lastname"),
 		expression.Name("email"),
+		expression.Name("title"),
 		expression.Name("projects"),
-		expression.Name("password"),
+		expression.Name("role"),
+		expression.Name("email_verified"),
+		expression.Name("totp_enabled"),
 		expression.Name("certifications"),
+		expression.Name(legacyCertificationsAttribute),
+		expression.Name("experiences"),
+		expression.Name("education"),
 	)
 	expr, err := expression.NewBuilder().WithFilter(filt).WithProjection(proj).Build()
 
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.ReadUsers")
+		return nil, "", errs.Wrap(err, "adapters.repository.dynamodb.ReadUsers")
 	}
 	params := &dynamodb.ScanInput{
 		ExpressionAttributeNames:  expr.Names(),
@@ -136,69 +257,377 @@ func (db *dynamoDbClient) ReadUsers() ([]*domain.User, error) {
 		ProjectionExpression:      expr.Projection(),
 		TableName:                 aws.String(db.usersTableName),
 	}
-	result, err := db.client.Scan(params)
+	items, next, err := db.scanPage(ctx, params, limit, c.Id)
 
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.ReadUsers")
+		return nil, "", errs.Wrap(err, "adapters.repository.dynamodb.ReadUsers")
 	}
 
-	for _, item := range result.Items {
-		var user domain.User
-
-		err = dynamodbattribute.UnmarshalMap(item, &user)
+	for _, item := range items {
+		user, err := unmarshalUser(item)
 		if err != nil {
-			return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.ReadUsers")
+			return nil, "", errs.Wrap(err, "adapters.repository.dynamodb.ReadUsers")
 		}
 
-		users = append(users, &user)
+		users = append(users, user)
 
 	}
-	return users, nil
+	sortUsers(users)
+	return users, next, nil
 }
 
-func (db *dynamoDbClient) UpdateUser(user *domain.User) (*domain.User, error) {
-	entityParsed, err := dynamodbattribute.MarshalMap(user)
+func (db *dynamoDbClient) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	if isEmailLockID(user.Id) {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", user.Id)
+	}
+
+	// ReadUser reports missing users as not found, so they are not created
+	current, err := db.ReadUser(ctx, user.Id)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.UpdateUser")
+		return nil, err
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      entityParsed,
-		TableName: aws.String(db.usersTableName),
+	// Only the profile and the credentials are written. Certifications,
+	// experience and education are changed by updateUserList, with their
+	// versions, and must not be overwritten with the lists read here
+	update := expression.Set(expression.Name("firstname"), expression.Value(user.FirstName)).
+		Set(expression.Name("lastname"), expression.Value(user.LastName)).
+		Set(expression.Name("email"), expression.Value(user.Email)).
+		Set(expression.Name("title"), expression.Value(user.Title)).
+		Set(expression.Name("password"), expression.Value(user.Password)).
+		Set(expression.Name("role"), expression.Value(user.Role)).
+		Set(expression.Name("email_verified"), expression.Value(user.EmailVerified)).
+		Set(expression.Name("totp_enabled"), expression.Value(user.TOTPEnabled)).
+		Set(expression.Name("totp_secret"), expression.Value(user.TOTPSecret)).
+		Set(expression.Name("totp_last_step"), expression.Value(user.TOTPLastStep)).
+		Set(expression.Name("recovery_codes"), expression.Value(user.RecoveryCodes)).
+		Set(expression.Name("identities"), expression.Value(user.Identities)).
+		Set(expression.Name("projects"), expression.Value(user.Projects))
+	// The email lock moved below is the one of the email that was read
+	cond := expression.AttributeExists(expression.Name("id")).
+		And(expression.Name("email").Equal(expression.Value(current.Email)))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.UpdateUser")
+	}
+
+	items := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName: aws.String(db.usersTableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String(user.Id)},
+				},
+				UpdateExpression:          expr.Update(),
+				ConditionExpression:       expr.Condition(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+			},
+		},
+	}
+	// Move the email lock when the email changes
+	if current.Email != user.Email {
+		items = append(items,
+			&dynamodb.TransactWriteItem{Put: db.putEmailLock(user)},
+			&dynamodb.TransactWriteItem{Delete: db.deleteEmailLock(current)},
+		)
 	}
 
-	_, err = db.client.PutItem(input)
+	_, err = db.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if isConditionalCheckFailedAt(err, 0) {
+		// The user was deleted or changed their email since it was read
+		if _, err := db.ReadUser(ctx, user.Id); err != nil {
+			return nil, err
+		}
+		return nil, domain.NewError(domain.ErrConflict, "user [ %s ] changed while they were being updated, try again", user.Id)
+	}
+	if isConditionalCheckFailed(err) {
+		return nil, domain.ErrEmailTaken
+	}
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.UpdateUser")
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.UpdateUser")
 	}
 
 	return user, nil
 }
 
-func (db *dynamoDbClient) DeleteUser(id string) error {
-	input := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(id),
+func (db *dynamoDbClient) DeleteUser(ctx context.Context, id string) error {
+	// ReadUser also refuses the ids of email locks
+	user, err := db.ReadUser(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		// Nothing to delete
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Remove what the user owned first, like ON DELETE CASCADE does in SQL.
+	// The user goes last, so a failed delete can be retried
+	if err := db.deleteUserProjects(ctx, id); err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteUser")
+	}
+	if err := db.deleteUserTokens(ctx, tokensUserIndex, "user_id", id, refreshTokenPrefix, resetTokenPrefix); err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteUser")
+	}
+	if err := db.deleteUserTokens(ctx, apiKeysOwnerIndex, "apikey_owner", id, apiKeyPrefix); err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteUser")
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					Key: map[string]*dynamodb.AttributeValue{
+						"id": {
+							S: aws.String(id),
+						},
+					},
+					TableName: aws.String(db.usersTableName),
+				},
+			},
+			{
+				Delete: db.deleteEmailLock(user),
 			},
 		},
-		TableName: aws.String(db.usersTableName),
 	}
 
-	res, err := db.client.DeleteItem(input)
-	if res == nil {
-		return errs.Wrap(errors.New(fmt.Sprintf("%s: %s", itemNotFound, err)), "adapters.repository.dynamodb.DeleteUser")
+	_, err = db.client.TransactWriteItemsWithContext(ctx, input)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteUser")
+	}
+	return nil
+}
+
+// userListAttempts is how many times updateUserList tries a change before
+// giving up on a list other requests keep changing.
+const userListAttempts = 3
+
+// updateUserList changes attribute, a list on the user item with userID, to
+// the list change returns for the user as read, leaving the rest of the item
+// alone. Every write bumps <attribute>_version and is conditional on the
+// version that was read, so concurrent changes are retried instead of lost.
+// It fails with ErrConflict if the list keeps changing. Errors are wrapped
+// with the name of method.
+func (db *dynamoDbClient) updateUserList(ctx context.Context, userID, attribute, method string, change func(user *domain.User) (interface{}, error)) error {
+	versionAttribute := attribute + "_version"
+	for attempt := 0; attempt < userListAttempts; attempt++ {
+		item, err := db.getUserItem(ctx, userID, true)
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb."+method)
+		}
+		if item == nil {
+			return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+		}
+		user, err := unmarshalUser(item)
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb."+method)
+		}
+		list, err := change(user)
+		if err != nil {
+			return err
+		}
+		value, err := dynamodbattribute.Marshal(list)
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb."+method)
+		}
+
+		var version int64
+		condition := "attribute_exists(id) AND attribute_not_exists(#version)"
+		values := map[string]*dynamodb.AttributeValue{":list": value}
+		if read, ok := item[versionAttribute]; ok {
+			if version, err = strconv.ParseInt(aws.StringValue(read.N), 10, 64); err != nil {
+				return errs.Wrap(err, "adapters.repository.dynamodb."+method)
+			}
+			condition = "#version = :read"
+			values[":read"] = read
+		}
+		values[":version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(version+1, 10))}
+
+		update := "SET #list = :list, #version = :version"
+		names := map[string]*string{
+			"#list":    aws.String(attribute),
+			"#version": aws.String(versionAttribute),
+		}
+		// The certifications read from the old attribute are now written
+		// under the new one
+		if _, ok := item[legacyCertificationsAttribute]; ok && attribute == "certifications" {
+			update += " REMOVE #legacy"
+			names["#legacy"] = aws.String(legacyCertificationsAttribute)
+		}
+
+		_, err = db.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(db.usersTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"id": {S: aws.String(userID)},
+			},
+			UpdateExpression:          aws.String(update),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		})
+		if isConditionalCheckFailed(err) {
+			// The list changed or the user was deleted since it was read
+			continue
+		}
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb."+method)
+		}
+		return nil
+	}
+	return domain.NewError(domain.ErrConflict, "%s of user [ %s ] changed while they were being updated, try again", attribute, userID)
+}
+
+// deleteUserProjects deletes the projects of the user with userID. Projects
+// have no index on their user, so the table is scanned.
+func (db *dynamoDbClient) deleteUserProjects(ctx context.Context, userID string) error {
+	filt := expression.Name("user_id").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithFilter(filt).Build()
+	if err != nil {
+		return err
 	}
+	var ids []*dynamodb.AttributeValue
+	err = db.client.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:                 aws.String(db.projectsTableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ProjectionExpression:      aws.String("id"),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			ids = append(ids, item["id"])
+		}
+		return true
+	})
 	if err != nil {
-		return errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.DeleteUser")
+		return err
+	}
+	return db.deleteItems(ctx, db.projectsTableName, ids)
+}
+
+// deleteItems deletes the items with ids from table.
+func (db *dynamoDbClient) deleteItems(ctx context.Context, table string, ids []*dynamodb.AttributeValue) error {
+	for _, id := range ids {
+		_, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(table),
+			Key: map[string]*dynamodb.AttributeValue{
+				"id": id,
+			},
+		})
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (db *dynamoDbClient) CreateProject(project *domain.Project) (*domain.Project, error) {
+// scanPage runs the scan in params from the item with id startID, or the
+// start of the table, until limit items passed its filter. A limit of 0 scans
+// the whole table. Scans are not ordered, so DynamoDB only orders items within
+// a page, the next page may hold items that sort before them. It returns the cursor of the next page, or "" after the last one.
+func (db *dynamoDbClient) scanPage(ctx context.Context, params *dynamodb.ScanInput, limit int, startID string) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	if startID != "" {
+		params.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(startID)},
+		}
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for {
+		if limit > 0 {
+			params.Limit = aws.Int64(int64(limit - len(items)))
+		}
+		result, err := db.client.ScanWithContext(ctx, params)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, result.Items...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, "", nil
+		}
+		if limit > 0 && len(items) >= limit {
+			return items, encodeCursor(pageCursor{Id: aws.StringValue(result.LastEvaluatedKey["id"].S)}), nil
+		}
+		params.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// Email locks are items in the users table keyed by emailLockPrefix and the
+// email, holding the id of the user the email belongs to. Writing them with
+// a condition makes email uniqueness atomic. They have no email attribute, so
+// they never show up in the email index.
+const emailLockPrefix = "email#"
+
+// isEmailLockID reports whether id is the key of an email lock. Ids come
+// from URLs, and reading a lock would tell whether an email is registered.
+func isEmailLockID(id string) bool {
+	return strings.HasPrefix(id, emailLockPrefix)
+}
+
+// putEmailLock claims the email of user, unless another user holds it.
+func (db *dynamoDbClient) putEmailLock(user *domain.User) *dynamodb.Put {
+	return &dynamodb.Put{
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":          {S: aws.String(emailLockPrefix + user.Email)},
+			"email_owner": {S: aws.String(user.Id)},
+		},
+		TableName:           aws.String(db.usersTableName),
+		ConditionExpression: aws.String("attribute_not_exists(id) OR email_owner = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(user.Id)},
+		},
+	}
+}
+
+// deleteEmailLock releases the email of user. Users created before email
+// locks existed have none, which is not an error.
+func (db *dynamoDbClient) deleteEmailLock(user *domain.User) *dynamodb.Delete {
+	return &dynamodb.Delete{
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(emailLockPrefix + user.Email)},
+		},
+		TableName:           aws.String(db.usersTableName),
+		ConditionExpression: aws.String("attribute_not_exists(id) OR email_owner = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(user.Id)},
+		},
+	}
+}
+
+// isConditionalCheckFailedAt reports whether a transaction was cancelled
+// because the condition of its item at index did not hold.
+func isConditionalCheckFailedAt(err error, index int) bool {
+	var canceled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &canceled) || index >= len(canceled.CancellationReasons) {
+		return false
+	}
+	return aws.StringValue(canceled.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+}
+
+// isConditionalCheckFailed reports whether a transaction was cancelled
+// because one of its conditions did not hold.
+func isConditionalCheckFailed(err error) bool {
+	var failed *dynamodb.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return true
+	}
+	var canceled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	for _, reason := range canceled.CancellationReasons {
+		if aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *dynamoDbClient) CreateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
 	entityParsed, err := dynamodbattribute.MarshalMap(project)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.CreateProject")
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.CreateProject")
 	}
 
 	input := &dynamodb.PutItemInput{
@@ -206,16 +635,16 @@ func (db *dynamoDbClient) CreateProject(project *domain.Project) (*domain.Projec
 		TableName: aws.String(db.projectsTableName),
 	}
 
-	_, err = db.client.PutItem(input)
+	_, err = db.client.PutItemWithContext(ctx, input)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.CreateProject")
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.CreateProject")
 	}
 
 	return project, nil
 }
 
-func (db *dynamoDbClient) ReadProject(id string) (*domain.Project, error) {
-	result, err := db.client.GetItem(&dynamodb.GetItemInput{
+func (db *dynamoDbClient) ReadProject(ctx context.Context, id string) (*domain.Project, error) {
+	result, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(db.projectsTableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
@@ -225,22 +654,26 @@ func (db *dynamoDbClient) ReadProject(id string) (*domain.Project, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadProject")
 	}
 
 	if result.Item == nil {
-		return nil, errors.New("Project not found")
+		return nil, domain.NewError(domain.ErrNotFound, "project with id [ %s ] not found", id)
 	}
 	var project domain.Project
 	err = dynamodbattribute.UnmarshalMap(result.Item, &project)
 	if err != nil {
-		return nil, err
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadProject")
 	}
 
 	return &project, nil
 }
 
-func (db *dynamoDbClient) ReadProjects() ([]*domain.Project, error) {
+func (db *dynamoDbClient) ReadProjects(ctx context.Context, limit int, cursor string) ([]*domain.Project, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
 	projects := []*domain.Project{}
 	filt := expression.Name("Id").AttributeNotExists()
 	proj := expression.NamesList(
@@ -248,12 +681,15 @@ func (db *dynamoDbClient) ReadProjects() ([]*domain.Project, error) {
 		expression.Name("title"),
 		expression.Name("body"),
 		expression.Name("user_id"),
+		expression.Name("user_name"),
+		expression.Name("user_title"),
+		expression.Name("rate"),
 		expression.Name("created_at"),
 	)
 	expr, err := expression.NewBuilder().WithFilter(filt).WithProjection(proj).Build()
 
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.ReadProjects")
+		return nil, "", errs.Wrap(err, "adapters.repository.dynamodb.ReadProjects")
 	}
 	params := &dynamodb.ScanInput{
 		ExpressionAttributeNames:  expr.Names(),
@@ -262,29 +698,30 @@ func (db *dynamoDbClient) ReadProjects() ([]*domain.Project, error) {
 		ProjectionExpression:      expr.Projection(),
 		TableName:                 aws.String(db.projectsTableName),
 	}
-	result, err := db.client.Scan(params)
+	items, next, err := db.scanPage(ctx, params, limit, c.Id)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.ReadProjects")
+		return nil, "", errs.Wrap(err, "adapters.repository.dynamodb.ReadProjects")
 	}
 
-	for _, item := range result.Items {
+	for _, item := range items {
 		var project domain.Project
 
 		err = dynamodbattribute.UnmarshalMap(item, &project)
 		if err != nil {
-			return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.ReadProjects")
+			return nil, "", errs.Wrap(err, "adapters.repository.dynamodb.ReadProjects")
 		}
 		projects = append(projects, &project)
 
 	}
+	sortProjects(projects)
 
-	return projects, nil
+	return projects, next, nil
 }
 
-func (db *dynamoDbClient) UpdateProject(project *domain.Project) (*domain.Project, error) {
+func (db *dynamoDbClient) UpdateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
 	entityParsed, err := dynamodbattribute.MarshalMap(project)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.UpdateProject")
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.UpdateProject")
 	}
 
 	input := &dynamodb.PutItemInput{
@@ -292,15 +729,15 @@ func (db *dynamoDbClient) UpdateProject(project *domain.Project) (*domain.Projec
 		TableName: aws.String(db.projectsTableName),
 	}
 
-	_, err = db.client.PutItem(input)
+	_, err = db.client.PutItemWithContext(ctx, input)
 	if err != nil {
-		return nil, errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.UpdateProject")
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.UpdateProject")
 	}
 
 	return project, nil
 }
 
-func (db *dynamoDbClient) DeleteProject(id string) error {
+func (db *dynamoDbClient) DeleteProject(ctx context.Context, id string) error {
 	input := &dynamodb.DeleteItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
@@ -310,12 +747,9 @@ func (db *dynamoDbClient) DeleteProject(id string) error {
 		TableName: aws.String(db.projectsTableName),
 	}
 
-	res, err := db.client.DeleteItem(input)
-	if res == nil {
-		return errs.Wrap(errors.New(fmt.Sprintf("%s: %s", itemNotFound, err)), "adapters.repository.dynamodb.DeleteProject")
-	}
+	_, err := db.client.DeleteItemWithContext(ctx, input)
 	if err != nil {
-		return errs.Wrap(errors.New(fmt.Sprintf("%s: %s", internalServerError, err)), "adapters.repository.dynamodb.DeleteProject")
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteProject")
 	}
 	return nil
 }
@@ -0,0 +1,156 @@
+/*
+Package name : repository
+File name : dynamodb_apikeys.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB store of API keys, kept in the tokens table
+	- Only API key items have an apikey_owner, the sparse index on it lists
+	  the keys of a user
+	- Keys that expire carry the expires_at TTL attribute
+*/
+
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	errs "github.com/pkg/errors"
+)
+
+const (
+	apiKeyPrefix = "apikey#"
+	// apiKeysOwnerIndex is the global secondary index listing the API keys
+	// of a user.
+	apiKeysOwnerIndex = "apikey-owner-index"
+)
+
+type apiKeyItem struct {
+	Id        string   `dynamodbav:"id"`
+	Owner     string   `dynamodbav:"apikey_owner"`
+	Name      string   `dynamodbav:"name"`
+	Hash      string   `dynamodbav:"hash"`
+	Scopes    []string `dynamodbav:"scopes"`
+	CreatedAt int64    `dynamodbav:"created_at"`
+	// Keys without an expiry have no TTL
+	ExpiresAt int64 `dynamodbav:"expires_at,omitempty"`
+}
+
+func (item apiKeyItem) apiKey() *domain.APIKey {
+	return &domain.APIKey{
+		Id:        strings.TrimPrefix(item.Id, apiKeyPrefix),
+		UserID:    item.Owner,
+		Name:      item.Name,
+		Hash:      item.Hash,
+		Scopes:    item.Scopes,
+		CreatedAt: item.CreatedAt,
+		ExpiresAt: item.ExpiresAt,
+	}
+}
+
+func apiKeyKey(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String(apiKeyPrefix + id)},
+	}
+}
+
+func (db *dynamoDbClient) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	item, err := dynamodbattribute.MarshalMap(apiKeyItem{
+		Id:        apiKeyPrefix + key.Id,
+		Owner:     key.UserID,
+		Name:      key.Name,
+		Hash:      key.Hash,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+	})
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.CreateAPIKey")
+	}
+	_, err = db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(db.tokensTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return domain.NewError(domain.ErrConflict, "API key [ %s ] exists", key.Id)
+	}
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.CreateAPIKey")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) ReadAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	result, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(db.tokensTableName),
+		Key:            apiKeyKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadAPIKey")
+	}
+	if result.Item == nil {
+		return nil, domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	var item apiKeyItem
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadAPIKey")
+	}
+	return item.apiKey(), nil
+}
+
+func (db *dynamoDbClient) ReadAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	keyCond := expression.Key("apikey_owner").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadAPIKeys")
+	}
+
+	keys := []*domain.APIKey{}
+	var unmarshalErr error
+	err = db.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.tokensTableName),
+		IndexName:                 aws.String(apiKeysOwnerIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []apiKeyItem
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		for _, item := range items {
+			keys = append(keys, item.apiKey())
+		}
+		return true
+	})
+	if err == nil {
+		err = unmarshalErr
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadAPIKeys")
+	}
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+func (db *dynamoDbClient) DeleteAPIKey(ctx context.Context, id string) error {
+	_, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(db.tokensTableName),
+		Key:                 apiKeyKey(id),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteAPIKey")
+	}
+	return nil
+}
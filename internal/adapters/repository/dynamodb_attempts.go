@@ -0,0 +1,123 @@
+/*
+Package name : repository
+File name : dynamodb_attempts.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB count of failed logins, kept in the tokens table
+	- DynamoDB removes a count once its expires_at TTL has passed
+*/
+
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	errs "github.com/pkg/errors"
+)
+
+const loginAttemptsPrefix = "attempts#"
+
+func loginAttemptsKey(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String(loginAttemptsPrefix + key)},
+	}
+}
+
+func (db *dynamoDbClient) RecordLoginFailure(ctx context.Context, key string, now, expiresAt time.Time) (*domain.LoginAttempts, error) {
+	values := map[string]*dynamodb.AttributeValue{
+		":zero":    {N: aws.String("0")},
+		":one":     {N: aws.String("1")},
+		":now":     {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		":expires": {N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))},
+	}
+	// The count is added to in place, so concurrent failures each count. The
+	// condition skips counts that expired but have not been removed yet.
+	result, err := db.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(db.tokensTableName),
+		Key:                       loginAttemptsKey(key),
+		UpdateExpression:          aws.String("SET failures = if_not_exists(failures, :zero) + :one, last_failure = :now, expires_at = :expires"),
+		ConditionExpression:       aws.String("attribute_not_exists(id) OR expires_at > :now"),
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if isConditionalCheckFailed(err) {
+		item := loginAttemptsKey(key)
+		item["failures"] = values[":one"]
+		item["last_failure"] = values[":now"]
+		item[tokensTTLAttribute] = values[":expires"]
+		_, err = db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(db.tokensTableName),
+			Item:      item,
+		})
+		if err != nil {
+			return nil, errs.Wrap(err, "adapters.repository.dynamodb.RecordLoginFailure")
+		}
+		return &domain.LoginAttempts{Key: key, Failures: 1, LastFailure: now.Unix(), ExpiresAt: expiresAt.Unix()}, nil
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.RecordLoginFailure")
+	}
+	attempts, err := loginAttemptsFromItem(key, result.Attributes)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.RecordLoginFailure")
+	}
+	return attempts, nil
+}
+
+func (db *dynamoDbClient) ReadLoginAttempts(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	result, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(db.tokensTableName),
+		Key:            loginAttemptsKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadLoginAttempts")
+	}
+	if result.Item == nil {
+		return nil, domain.NewError(domain.ErrNotFound, "no failed logins for %s", key)
+	}
+	attempts, err := loginAttemptsFromItem(key, result.Item)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadLoginAttempts")
+	}
+	// DynamoDB can take a while to delete expired items
+	if attempts.ExpiresAt <= time.Now().Unix() {
+		return nil, domain.NewError(domain.ErrNotFound, "no failed logins for %s", key)
+	}
+	return attempts, nil
+}
+
+func (db *dynamoDbClient) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Key:       loginAttemptsKey(key),
+	})
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.ResetLoginAttempts")
+	}
+	return nil
+}
+
+func loginAttemptsFromItem(key string, item map[string]*dynamodb.AttributeValue) (*domain.LoginAttempts, error) {
+	attempts := domain.LoginAttempts{Key: key}
+	var err error
+	for name, value := range map[string]*int64{"last_failure": &attempts.LastFailure, tokensTTLAttribute: &attempts.ExpiresAt} {
+		if item[name] == nil {
+			continue
+		}
+		if *value, err = strconv.ParseInt(aws.StringValue(item[name].N), 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if item["failures"] != nil {
+		if attempts.Failures, err = strconv.Atoi(aws.StringValue(item["failures"].N)); err != nil {
+			return nil, err
+		}
+	}
+	return &attempts, nil
+}
@@ -0,0 +1,78 @@
+/*
+Package name : repository
+File name : dynamodb_certifications.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB store of certifications, kept in the certifications
+	  list of the user item
+	- Changes go through updateUserList, which retries when the list changed
+	  since it was read
+*/
+
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *dynamoDbClient) CreateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	err := db.updateUserList(ctx, certification.UserID, "certifications", "CreateCertification", func(user *domain.User) (interface{}, error) {
+		if certificationIndex(user, certification.Id) >= 0 {
+			return nil, domain.NewError(domain.ErrConflict, "certification [ %s ] exists", certification.Id)
+		}
+		return append(user.Certifications, certification), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return certification, nil
+}
+
+func (db *dynamoDbClient) ReadCertification(ctx context.Context, userID, id string) (*domain.Certification, error) {
+	user, err := db.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	i := certificationIndex(user, id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", id)
+	}
+	return user.Certifications[i], nil
+}
+
+func (db *dynamoDbClient) ReadCertifications(ctx context.Context, userID string) ([]*domain.Certification, error) {
+	user, err := db.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	certifications := append([]*domain.Certification{}, user.Certifications...)
+	sortCertifications(certifications)
+	return certifications, nil
+}
+
+func (db *dynamoDbClient) UpdateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	err := db.updateUserList(ctx, certification.UserID, "certifications", "UpdateCertification", func(user *domain.User) (interface{}, error) {
+		i := certificationIndex(user, certification.Id)
+		if i < 0 {
+			return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", certification.Id)
+		}
+		user.Certifications[i] = certification
+		return user.Certifications, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return certification, nil
+}
+
+func (db *dynamoDbClient) DeleteCertification(ctx context.Context, userID, id string) error {
+	return db.updateUserList(ctx, userID, "certifications", "DeleteCertification", func(user *domain.User) (interface{}, error) {
+		i := certificationIndex(user, id)
+		if i < 0 {
+			return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", id)
+		}
+		return append(user.Certifications[:i], user.Certifications[i+1:]...), nil
+	})
+}
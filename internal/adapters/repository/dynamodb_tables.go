@@ -0,0 +1,216 @@
+/*
+Package name : repository
+File name : dynamodb_tables.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB table definitions
+	- Creates missing tables at startup, e.g. against DynamoDB Local
+*/
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	errs "github.com/pkg/errors"
+)
+
+// usersEmailIndex is the global secondary index used to look users up by
+// email instead of scanning the users table.
+const usersEmailIndex = "email-index"
+
+// CreateDynamoDBTables creates the tables the DynamoDB repository uses if
+// they do not exist yet and waits for them to become active.
+func CreateDynamoDBTables(ctx context.Context, c *config.AppConfig) error {
+	db := newDynamoDBClient(c)
+	for _, table := range db.tables() {
+		if err := db.createTable(ctx, table); err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb.CreateDynamoDBTables")
+		}
+	}
+	if err := db.enableTTL(ctx, db.tokensTableName, tokensTTLAttribute); err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.CreateDynamoDBTables")
+	}
+	return nil
+}
+
+// tables describes every table with its keys and indexes.
+func (db *dynamoDbClient) tables() []*dynamodb.CreateTableInput {
+	return []*dynamodb.CreateTableInput{
+		{
+			TableName: aws.String(db.usersTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+				{AttributeName: aws.String("email"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			},
+			GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+				{
+					IndexName: aws.String(usersEmailIndex),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{AttributeName: aws.String("email"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					},
+					Projection: &dynamodb.Projection{
+						ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+					},
+				},
+			},
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		},
+		{
+			TableName: aws.String(db.projectsTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			},
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		},
+		{
+			TableName: aws.String(db.tokensTableName),
+			AttributeDefinitions: []*dynamodb.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+				{AttributeName: aws.String("family_id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+				{AttributeName: aws.String("user_id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+				{AttributeName: aws.String("apikey_owner"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			},
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			},
+			GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+				{
+					IndexName: aws.String(tokensFamilyIndex),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{AttributeName: aws.String("family_id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					},
+					Projection: &dynamodb.Projection{
+						ProjectionType: aws.String(dynamodb.ProjectionTypeKeysOnly),
+					},
+				},
+				{
+					IndexName: aws.String(tokensUserIndex),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{AttributeName: aws.String("user_id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					},
+					Projection: &dynamodb.Projection{
+						ProjectionType: aws.String(dynamodb.ProjectionTypeKeysOnly),
+					},
+				},
+				{
+					IndexName: aws.String(apiKeysOwnerIndex),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{AttributeName: aws.String("apikey_owner"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					},
+					Projection: &dynamodb.Projection{
+						ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+					},
+				},
+			},
+			BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		},
+	}
+}
+
+func (db *dynamoDbClient) createTable(ctx context.Context, table *dynamodb.CreateTableInput) error {
+	_, err := db.client.CreateTableWithContext(ctx, table)
+	if err == nil {
+		return db.client.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: table.TableName,
+		})
+	}
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != dynamodb.ErrCodeResourceInUseException {
+		return err
+	}
+	// The table already exists, add the indexes it was created without
+	return db.createIndexes(ctx, table)
+}
+
+func (db *dynamoDbClient) createIndexes(ctx context.Context, table *dynamodb.CreateTableInput) error {
+	if err := db.client.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: table.TableName}); err != nil {
+		return err
+	}
+	for _, index := range table.GlobalSecondaryIndexes {
+		existing, err := db.indexStatus(ctx, table.TableName, index.IndexName)
+		if err != nil {
+			return err
+		}
+		if existing == "" {
+			_, err = db.client.UpdateTableWithContext(ctx, &dynamodb.UpdateTableInput{
+				TableName:            table.TableName,
+				AttributeDefinitions: table.AttributeDefinitions,
+				GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+					{
+						Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+							IndexName:  index.IndexName,
+							KeySchema:  index.KeySchema,
+							Projection: index.Projection,
+						},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		// Queries fail until the index has been backfilled
+		for existing != dynamodb.IndexStatusActive {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			if existing, err = db.indexStatus(ctx, table.TableName, index.IndexName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexStatus returns the status of a global secondary index, or "" if the
+// table has no such index.
+func (db *dynamoDbClient) indexStatus(ctx context.Context, tableName, indexName *string) (string, error) {
+	res, err := db.client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: tableName})
+	if err != nil {
+		return "", err
+	}
+	for _, index := range res.Table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == aws.StringValue(indexName) {
+			return aws.StringValue(index.IndexStatus), nil
+		}
+	}
+	return "", nil
+}
+
+// enableTTL makes DynamoDB delete the items of a table once the time in
+// attribute has passed.
+func (db *dynamoDbClient) enableTTL(ctx context.Context, tableName, attribute string) error {
+	res, err := db.client.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return err
+	}
+	switch aws.StringValue(res.TimeToLiveDescription.TimeToLiveStatus) {
+	case dynamodb.TimeToLiveStatusEnabled, dynamodb.TimeToLiveStatusEnabling:
+		return nil
+	}
+	_, err = db.client.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(attribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/repository/repositorytest"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/uuid"
+)
+
+// TestDynamoDBRepository runs the contract suite against DynamoDB Local or
+// LocalStack at DYNAMODB_ENDPOINT, e.g.
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test ./internal/adapters/repository/
+//
+// It is skipped when DYNAMODB_ENDPOINT is not set.
+func TestDynamoDBRepository(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set")
+	}
+	c := &config.AppConfig{
+		UsersTable:         "TestUsers",
+		ProjectTable:       "TestProjects",
+		TokenTable:         "TestTokens",
+		AWSDefaultRegion:   "us-east-1",
+		AWSAccessKeyID:     "local",
+		AWSAccessSecretKey: "local",
+		DynamoDBEndpoint:   endpoint,
+	}
+	if err := CreateDynamoDBTables(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewDynamoDBRepository(c)
+	repositorytest.Run(t, func(t *testing.T) ports.PortfolioRepository {
+		return repo
+	})
+
+	t.Run("Read user with the id of an email lock", func(t *testing.T) {
+		ctx := context.Background()
+		id := uuid.New().String()
+		user := &domain.User{Id: id, Email: id + "@example.com", Role: domain.RoleOwner}
+		if _, err := repo.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		defer repo.DeleteUser(ctx, id)
+
+		// Email locks live next to the users and must not tell which emails
+		// are registered
+		if _, err := repo.ReadUser(ctx, emailLockPrefix+user.Email); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading the user with id %s%s returned %v, want %v", emailLockPrefix, user.Email, err, domain.ErrNotFound)
+		}
+		if _, err := repo.CreateCertification(ctx, &domain.Certification{Id: uuid.New().String(), UserID: emailLockPrefix + user.Email, Title: "CKAD"}); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("creating a certification of user %s%s returned %v, want %v", emailLockPrefix, user.Email, err, domain.ErrNotFound)
+		}
+	})
+}
+
+func TestUnmarshalLegacyCertifications(t *testing.T) {
+	// Users written before certifications were renamed
+	item := map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String("1")},
+		legacyCertificationsAttribute: {L: []*dynamodb.AttributeValue{
+			{M: map[string]*dynamodb.AttributeValue{
+				"id":         {S: aws.String("2")},
+				"title":      {S: aws.String("CKAD")},
+				"decription": {S: aws.String("Kubernetes")},
+			}},
+		}},
+	}
+	user, err := unmarshalUser(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Certifications) != 1 || user.Certifications[0].Title != "CKAD" || user.Certifications[0].Description != "Kubernetes" {
+		t.Errorf("unmarshalled certifications %+v, want CKAD described as Kubernetes", user.Certifications)
+	}
+
+	// The new attribute wins once the certifications were written again
+	item["certifications"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+	if user, err = unmarshalUser(item); err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Certifications) != 0 {
+		t.Errorf("unmarshalled certifications %+v, want none", user.Certifications)
+	}
+}
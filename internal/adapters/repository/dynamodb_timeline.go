@@ -0,0 +1,138 @@
+/*
+Package name : repository
+File name : dynamodb_timeline.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB store of experience and education, kept in the
+	  experiences and education lists of the user item
+	- Changes go through updateUserList, which retries when the list changed
+	  since it was read
+*/
+
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *dynamoDbClient) CreateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	err := db.updateUserList(ctx, experience.UserID, "experiences", "CreateExperience", func(user *domain.User) (interface{}, error) {
+		if experienceIndex(user, experience.Id) >= 0 {
+			return nil, domain.NewError(domain.ErrConflict, "experience [ %s ] exists", experience.Id)
+		}
+		return append(user.Experiences, experience), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return experience, nil
+}
+
+func (db *dynamoDbClient) ReadExperience(ctx context.Context, userID, id string) (*domain.Experience, error) {
+	user, err := db.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	i := experienceIndex(user, id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", id)
+	}
+	return user.Experiences[i], nil
+}
+
+func (db *dynamoDbClient) ReadExperiences(ctx context.Context, userID string) ([]*domain.Experience, error) {
+	user, err := db.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	experiences := append([]*domain.Experience{}, user.Experiences...)
+	sortExperiences(experiences)
+	return experiences, nil
+}
+
+func (db *dynamoDbClient) UpdateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	err := db.updateUserList(ctx, experience.UserID, "experiences", "UpdateExperience", func(user *domain.User) (interface{}, error) {
+		i := experienceIndex(user, experience.Id)
+		if i < 0 {
+			return nil, domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", experience.Id)
+		}
+		user.Experiences[i] = experience
+		return user.Experiences, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return experience, nil
+}
+
+func (db *dynamoDbClient) DeleteExperience(ctx context.Context, userID, id string) error {
+	return db.updateUserList(ctx, userID, "experiences", "DeleteExperience", func(user *domain.User) (interface{}, error) {
+		i := experienceIndex(user, id)
+		if i < 0 {
+			return nil, domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", id)
+		}
+		return append(user.Experiences[:i], user.Experiences[i+1:]...), nil
+	})
+}
+
+func (db *dynamoDbClient) CreateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	err := db.updateUserList(ctx, education.UserID, "education", "CreateEducation", func(user *domain.User) (interface{}, error) {
+		if educationIndex(user, education.Id) >= 0 {
+			return nil, domain.NewError(domain.ErrConflict, "education [ %s ] exists", education.Id)
+		}
+		return append(user.Education, education), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return education, nil
+}
+
+func (db *dynamoDbClient) ReadEducation(ctx context.Context, userID, id string) (*domain.Education, error) {
+	user, err := db.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	i := educationIndex(user, id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", id)
+	}
+	return user.Education[i], nil
+}
+
+func (db *dynamoDbClient) ReadEducations(ctx context.Context, userID string) ([]*domain.Education, error) {
+	user, err := db.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	education := append([]*domain.Education{}, user.Education...)
+	sortEducation(education)
+	return education, nil
+}
+
+func (db *dynamoDbClient) UpdateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	err := db.updateUserList(ctx, education.UserID, "education", "UpdateEducation", func(user *domain.User) (interface{}, error) {
+		i := educationIndex(user, education.Id)
+		if i < 0 {
+			return nil, domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", education.Id)
+		}
+		user.Education[i] = education
+		return user.Education, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return education, nil
+}
+
+func (db *dynamoDbClient) DeleteEducation(ctx context.Context, userID, id string) error {
+	return db.updateUserList(ctx, userID, "education", "DeleteEducation", func(user *domain.User) (interface{}, error) {
+		i := educationIndex(user, id)
+		if i < 0 {
+			return nil, domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", id)
+		}
+		return append(user.Education[:i], user.Education[i+1:]...), nil
+	})
+}
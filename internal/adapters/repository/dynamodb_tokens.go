@@ -0,0 +1,265 @@
+/*
+Package name : repository
+File name : dynamodb_tokens.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB store of revoked, refresh and password reset tokens
+	- Items carry an expires_at TTL attribute so DynamoDB removes them once
+	  the token has expired
+*/
+
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// tokensTTLAttribute is the attribute DynamoDB reads to expire token items.
+	tokensTTLAttribute = "expires_at"
+	// tokensFamilyIndex is the global secondary index listing the refresh
+	// tokens of a family.
+	tokensFamilyIndex = "family-index"
+	// tokensUserIndex is the global secondary index listing the refresh and
+	// password reset tokens of a user.
+	tokensUserIndex    = "user-index"
+	refreshTokenPrefix = "refresh#"
+	resetTokenPrefix   = "reset#"
+)
+
+// revokedTokenKey returns the key of the item recording that token id was
+// revoked. Other kinds of tokens share the table under other prefixes.
+func revokedTokenKey(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String("revoked#" + id)},
+	}
+}
+
+func (db *dynamoDbClient) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	item := revokedTokenKey(id)
+	item[tokensTTLAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))}
+
+	_, err := db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Item:      item,
+	})
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.RevokeToken")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	result, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(db.tokensTableName),
+		Key:            revokedTokenKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, errs.Wrap(err, "adapters.repository.dynamodb.IsTokenRevoked")
+	}
+	if result.Item == nil || result.Item[tokensTTLAttribute] == nil {
+		return false, nil
+	}
+	// DynamoDB can take a while to delete expired items
+	expiresAt, err := strconv.ParseInt(aws.StringValue(result.Item[tokensTTLAttribute].N), 10, 64)
+	if err != nil {
+		return false, errs.Wrap(err, "adapters.repository.dynamodb.IsTokenRevoked")
+	}
+	return expiresAt > time.Now().Unix(), nil
+}
+
+func (db *dynamoDbClient) CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error {
+	_, err := db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":               {S: aws.String(refreshTokenPrefix + token.Id)},
+			"family_id":        {S: aws.String(token.FamilyID)},
+			"user_id":          {S: aws.String(token.UserID)},
+			tokensTTLAttribute: {N: aws.String(strconv.FormatInt(token.ExpiresAt, 10))},
+			"used":             {BOOL: aws.Bool(token.Used)},
+		},
+	})
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.CreateRefreshToken")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) ReadRefreshToken(ctx context.Context, id string) (*domain.RefreshToken, error) {
+	result, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(refreshTokenPrefix + id)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadRefreshToken")
+	}
+	if result.Item == nil {
+		return nil, domain.NewError(domain.ErrNotFound, "refresh token not found")
+	}
+	expiresAt, err := strconv.ParseInt(aws.StringValue(result.Item[tokensTTLAttribute].N), 10, 64)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ReadRefreshToken")
+	}
+	return &domain.RefreshToken{
+		Id:        strings.TrimPrefix(aws.StringValue(result.Item["id"].S), refreshTokenPrefix),
+		FamilyID:  aws.StringValue(result.Item["family_id"].S),
+		UserID:    aws.StringValue(result.Item["user_id"].S),
+		ExpiresAt: expiresAt,
+		Used:      aws.BoolValue(result.Item["used"].BOOL),
+	}, nil
+}
+
+func (db *dynamoDbClient) UseRefreshToken(ctx context.Context, id string) error {
+	// The condition lets only one of two concurrent requests with the same
+	// token through
+	_, err := db.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(refreshTokenPrefix + id)},
+		},
+		UpdateExpression:    aws.String("SET used = :used"),
+		ConditionExpression: aws.String("attribute_exists(id) AND used = :unused"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":used":   {BOOL: aws.Bool(true)},
+			":unused": {BOOL: aws.Bool(false)},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		if _, err := db.ReadRefreshToken(ctx, id); err != nil {
+			return err
+		}
+		return domain.NewError(domain.ErrConflict, "refresh token was used before")
+	}
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.UseRefreshToken")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) DeleteRefreshTokenFamily(ctx context.Context, familyID string) error {
+	keyCond := expression.Key("family_id").Equal(expression.Value(familyID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteRefreshTokenFamily")
+	}
+
+	err = db.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.tokensTableName),
+		IndexName:                 aws.String(tokensFamilyIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			_, err = db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(db.tokensTableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": item["id"],
+				},
+			})
+			if err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteRefreshTokenFamily")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	if err := db.deleteUserTokens(ctx, tokensUserIndex, "user_id", userID, refreshTokenPrefix); err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.DeleteUserRefreshTokens")
+	}
+	return nil
+}
+
+// deleteUserTokens deletes the items of the tokens table that index lists
+// under the user with userID, if their id starts with one of prefixes.
+// Kinds of tokens share the table and some of its indexes.
+func (db *dynamoDbClient) deleteUserTokens(ctx context.Context, index, attribute, userID string, prefixes ...string) error {
+	keyCond := expression.Key(attribute).Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return err
+	}
+	var ids []*dynamodb.AttributeValue
+	err = db.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(db.tokensTableName),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			id := aws.StringValue(item["id"].S)
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(id, prefix) {
+					ids = append(ids, item["id"])
+					break
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return db.deleteItems(ctx, db.tokensTableName, ids)
+}
+
+func (db *dynamoDbClient) CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+	_, err := db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":               {S: aws.String(resetTokenPrefix + token.Id)},
+			"user_id":          {S: aws.String(token.UserID)},
+			tokensTTLAttribute: {N: aws.String(strconv.FormatInt(token.ExpiresAt, 10))},
+		},
+	})
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.CreatePasswordResetToken")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) ConsumePasswordResetToken(ctx context.Context, id string) (*domain.PasswordResetToken, error) {
+	// Deleting the item returns it to one request only
+	result, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(db.tokensTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(resetTokenPrefix + id)},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ConsumePasswordResetToken")
+	}
+	if result.Attributes == nil {
+		return nil, domain.NewError(domain.ErrNotFound, "password reset token not found")
+	}
+	expiresAt, err := strconv.ParseInt(aws.StringValue(result.Attributes[tokensTTLAttribute].N), 10, 64)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.dynamodb.ConsumePasswordResetToken")
+	}
+	return &domain.PasswordResetToken{
+		Id:        id,
+		UserID:    aws.StringValue(result.Attributes["user_id"].S),
+		ExpiresAt: expiresAt,
+	}, nil
+}
@@ -0,0 +1,98 @@
+/*
+Package name : repository
+File name : dynamodb_totp.go
+Author : Antony Injila
+Description :
+	- Host the DynamoDB store of used two-factor codes
+	- Codes are used up with conditional updates, so concurrent logins can't
+	  use one code twice
+*/
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	errs "github.com/pkg/errors"
+)
+
+func (db *dynamoDbClient) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	if isEmailLockID(userID) {
+		return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	_, err := db.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(db.usersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(userID)},
+		},
+		UpdateExpression:    aws.String("SET totp_last_step = :step"),
+		ConditionExpression: aws.String("attribute_exists(id) AND (attribute_not_exists(totp_last_step) OR totp_last_step < :step)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":step": {N: aws.String(strconv.FormatInt(step, 10))},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		if _, err := db.ReadUser(ctx, userID); err != nil {
+			return err
+		}
+		return domain.NewError(domain.ErrConflict, "the code was used before")
+	}
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.dynamodb.UseTOTPStep")
+	}
+	return nil
+}
+
+func (db *dynamoDbClient) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	// The code is removed by its position, the condition makes sure it is
+	// still there
+	for attempt := 0; attempt < userListAttempts; attempt++ {
+		item, err := db.getUserItem(ctx, userID, true)
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb.UseRecoveryCode")
+		}
+		if item == nil {
+			return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+		}
+		user, err := unmarshalUser(item)
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb.UseRecoveryCode")
+		}
+		index := -1
+		for i, code := range user.RecoveryCodes {
+			if code == hash {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return domain.NewError(domain.ErrConflict, "the recovery code was used before")
+		}
+
+		_, err = db.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(db.usersTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"id": {S: aws.String(userID)},
+			},
+			UpdateExpression:    aws.String(fmt.Sprintf("REMOVE recovery_codes[%d]", index)),
+			ConditionExpression: aws.String(fmt.Sprintf("recovery_codes[%d] = :code", index)),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":code": {S: aws.String(hash)},
+			},
+		})
+		if isConditionalCheckFailed(err) {
+			// The codes changed since they were read
+			continue
+		}
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.dynamodb.UseRecoveryCode")
+		}
+		return nil
+	}
+	return domain.NewError(domain.ErrConflict, "recovery codes of user [ %s ] changed while they were being used, try again", userID)
+}
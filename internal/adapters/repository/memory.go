@@ -0,0 +1,271 @@
+/*
+Package name : repository
+File name : memory.go
+Author : Antony Injila
+Description :
+	- Host an in-memory implementation of the portfolio repository
+	- Used by the tests and for local development without AWS
+*/
+
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+)
+
+type inMemoryClient struct {
+	mu       sync.RWMutex
+	users    map[string]domain.User
+	projects map[string]domain.Project
+	// revoked maps the id of a revoked token to when the token expires
+	revoked       map[string]time.Time
+	refreshTokens map[string]domain.RefreshToken
+	resetTokens   map[string]domain.PasswordResetToken
+	apiKeys       map[string]domain.APIKey
+	*inMemoryLoginAttempts
+}
+
+func NewInMemoryRepository() ports.PortfolioRepository {
+	return &inMemoryClient{
+		users:         map[string]domain.User{},
+		projects:      map[string]domain.Project{},
+		revoked:       map[string]time.Time{},
+		refreshTokens: map[string]domain.RefreshToken{},
+		resetTokens:   map[string]domain.PasswordResetToken{},
+		apiKeys:       map[string]domain.APIKey{},
+
+		inMemoryLoginAttempts: newInMemoryLoginAttempts(),
+	}
+}
+
+func (db *inMemoryClient) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.emailTaken(user) {
+		return nil, domain.ErrEmailTaken
+	}
+	db.users[user.Id] = copyUser(user)
+	return user, nil
+}
+
+func (db *inMemoryClient) ReadUser(ctx context.Context, id string) (*domain.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", id)
+	}
+	res := copyUser(&user)
+	return &res, nil
+}
+
+func (db *inMemoryClient) ReadUserWithEmail(ctx context.Context, email string) (*domain.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, user := range db.users {
+		if user.Email == email {
+			res := copyUser(&user)
+			return &res, nil
+		}
+	}
+	return nil, domain.NewError(domain.ErrNotFound, "user with email [ %s ] not found", email)
+}
+
+func (db *inMemoryClient) ReadUserWithIdentity(ctx context.Context, key string) (*domain.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, user := range db.users {
+		for _, identity := range user.Identities {
+			if identity == key {
+				res := copyUser(&user)
+				return &res, nil
+			}
+		}
+	}
+	return nil, domain.NewError(domain.ErrNotFound, "user with identity [ %s ] not found", key)
+}
+
+func (db *inMemoryClient) ReadUsers(ctx context.Context, limit int, cursor string) ([]*domain.User, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	users := []*domain.User{}
+	for _, item := range db.users {
+		if afterUser(&item, c) {
+			user := copyUser(&item)
+			users = append(users, withoutCredentials(&user))
+		}
+	}
+	sortUsers(users)
+
+	if limit <= 0 || len(users) <= limit {
+		return users, "", nil
+	}
+	users = users[:limit]
+	return users, encodeCursor(pageCursor{Id: users[limit-1].Id}), nil
+}
+
+func (db *inMemoryClient) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	current, ok := db.users[user.Id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", user.Id)
+	}
+	if db.emailTaken(user) {
+		return nil, domain.ErrEmailTaken
+	}
+	// Certifications, experience and education are changed by their own
+	// methods, keep the stored ones
+	updated := copyUser(user)
+	updated.Certifications = current.Certifications
+	updated.Experiences = current.Experiences
+	updated.Education = current.Education
+	db.users[user.Id] = updated
+	return user, nil
+}
+
+func (db *inMemoryClient) DeleteUser(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.users, id)
+	// Remove what the user owned, like ON DELETE CASCADE does in SQL
+	for key, project := range db.projects {
+		if project.UserID == id {
+			delete(db.projects, key)
+		}
+	}
+	for key, apiKey := range db.apiKeys {
+		if apiKey.UserID == id {
+			delete(db.apiKeys, key)
+		}
+	}
+	for key, token := range db.refreshTokens {
+		if token.UserID == id {
+			delete(db.refreshTokens, key)
+		}
+	}
+	for key, token := range db.resetTokens {
+		if token.UserID == id {
+			delete(db.resetTokens, key)
+		}
+	}
+	return nil
+}
+
+func (db *inMemoryClient) CreateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.projects[project.Id] = *project
+	return project, nil
+}
+
+func (db *inMemoryClient) ReadProject(ctx context.Context, id string) (*domain.Project, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	project, ok := db.projects[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "project with id [ %s ] not found", id)
+	}
+	return &project, nil
+}
+
+func (db *inMemoryClient) ReadProjects(ctx context.Context, limit int, cursor string) ([]*domain.Project, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	projects := []*domain.Project{}
+	for _, item := range db.projects {
+		if afterProject(&item, c) {
+			project := item
+			projects = append(projects, &project)
+		}
+	}
+	sortProjects(projects)
+
+	if limit <= 0 || len(projects) <= limit {
+		return projects, "", nil
+	}
+	projects = projects[:limit]
+	last := projects[limit-1]
+	return projects, encodeCursor(pageCursor{Id: last.Id, CreateAt: last.CreateAt}), nil
+}
+
+func (db *inMemoryClient) UpdateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.projects[project.Id] = *project
+	return project, nil
+}
+
+func (db *inMemoryClient) DeleteProject(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.projects, id)
+	return nil
+}
+
+// emailTaken reports whether another user already has the email of user.
+// Callers must hold the lock.
+func (db *inMemoryClient) emailTaken(user *domain.User) bool {
+	for id, item := range db.users {
+		if id != user.Id && item.Email == user.Email {
+			return true
+		}
+	}
+	return false
+}
+
+// copyUser returns a copy of user that shares no slices or pointers with it,
+// so callers can't mutate stored items behind the lock.
+func copyUser(user *domain.User) domain.User {
+	res := *user
+	res.Projects = nil
+	for _, project := range user.Projects {
+		p := *project
+		res.Projects = append(res.Projects, &p)
+	}
+	res.Certifications = nil
+	for _, certification := range user.Certifications {
+		c := *certification
+		res.Certifications = append(res.Certifications, &c)
+	}
+	res.Experiences = nil
+	for _, experience := range user.Experiences {
+		e := copyExperience(experience)
+		res.Experiences = append(res.Experiences, &e)
+	}
+	res.Education = nil
+	for _, education := range user.Education {
+		e := *education
+		res.Education = append(res.Education, &e)
+	}
+	res.RecoveryCodes = append([]string(nil), user.RecoveryCodes...)
+	res.Identities = append([]string(nil), user.Identities...)
+	return res
+}
@@ -0,0 +1,70 @@
+/*
+Package name : repository
+File name : memory_apikeys.go
+Author : Antony Injila
+Description :
+	- Host the in-memory store of API keys
+*/
+
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *inMemoryClient) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.apiKeys[key.Id]; ok {
+		return domain.NewError(domain.ErrConflict, "API key [ %s ] exists", key.Id)
+	}
+	db.apiKeys[key.Id] = copyAPIKey(key)
+	return nil
+}
+
+func (db *inMemoryClient) ReadAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	key, ok := db.apiKeys[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	res := copyAPIKey(&key)
+	return &res, nil
+}
+
+func (db *inMemoryClient) ReadAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := []*domain.APIKey{}
+	for _, key := range db.apiKeys {
+		if key.UserID == userID {
+			res := copyAPIKey(&key)
+			keys = append(keys, &res)
+		}
+	}
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+func (db *inMemoryClient) DeleteAPIKey(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.apiKeys[id]; !ok {
+		return domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	delete(db.apiKeys, id)
+	return nil
+}
+
+func copyAPIKey(key *domain.APIKey) domain.APIKey {
+	res := *key
+	res.Scopes = append([]string(nil), key.Scopes...)
+	return res
+}
@@ -0,0 +1,73 @@
+/*
+Package name : repository
+File name : memory_attempts.go
+Author : Antony Injila
+Description :
+	- Host the in-memory count of failed logins
+	- The in-memory repository keeps its counts here, other repositories can
+	  use it to keep the counts off the database
+*/
+
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+)
+
+type inMemoryLoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempts
+}
+
+func NewInMemoryLoginAttemptStore() ports.LoginAttemptStore {
+	return newInMemoryLoginAttempts()
+}
+
+func newInMemoryLoginAttempts() *inMemoryLoginAttempts {
+	return &inMemoryLoginAttempts{
+		attempts: map[string]domain.LoginAttempts{},
+	}
+}
+
+func (db *inMemoryLoginAttempts) RecordLoginFailure(ctx context.Context, key string, now, expiresAt time.Time) (*domain.LoginAttempts, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Forget counts that have expired
+	for k, stored := range db.attempts {
+		if stored.ExpiresAt <= now.Unix() {
+			delete(db.attempts, k)
+		}
+	}
+	attempts := db.attempts[key]
+	attempts.Key = key
+	attempts.Failures++
+	attempts.LastFailure = now.Unix()
+	attempts.ExpiresAt = expiresAt.Unix()
+	db.attempts[key] = attempts
+	return &attempts, nil
+}
+
+func (db *inMemoryLoginAttempts) ReadLoginAttempts(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	attempts, ok := db.attempts[key]
+	if !ok || attempts.ExpiresAt <= time.Now().Unix() {
+		return nil, domain.NewError(domain.ErrNotFound, "no failed logins for %s", key)
+	}
+	return &attempts, nil
+}
+
+func (db *inMemoryLoginAttempts) ResetLoginAttempts(ctx context.Context, key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.attempts, key)
+	return nil
+}
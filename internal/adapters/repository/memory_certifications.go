@@ -0,0 +1,113 @@
+/*
+Package name : repository
+File name : memory_certifications.go
+Author : Antony Injila
+Description :
+	- Host the in-memory store of certifications, kept on the user they belong to
+*/
+
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *inMemoryClient) CreateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[certification.UserID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", certification.UserID)
+	}
+	if certificationIndex(&user, certification.Id) >= 0 {
+		return nil, domain.NewError(domain.ErrConflict, "certification [ %s ] exists", certification.Id)
+	}
+	c := *certification
+	user.Certifications = append(user.Certifications, &c)
+	db.users[user.Id] = copyUser(&user)
+	return certification, nil
+}
+
+func (db *inMemoryClient) ReadCertification(ctx context.Context, userID, id string) (*domain.Certification, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	i := certificationIndex(&user, id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", id)
+	}
+	res := *user.Certifications[i]
+	return &res, nil
+}
+
+func (db *inMemoryClient) ReadCertifications(ctx context.Context, userID string) ([]*domain.Certification, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	certifications := []*domain.Certification{}
+	for _, certification := range user.Certifications {
+		c := *certification
+		certifications = append(certifications, &c)
+	}
+	sortCertifications(certifications)
+	return certifications, nil
+}
+
+func (db *inMemoryClient) UpdateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[certification.UserID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", certification.UserID)
+	}
+	i := certificationIndex(&user, certification.Id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", certification.Id)
+	}
+	user = copyUser(&user)
+	c := *certification
+	user.Certifications[i] = &c
+	db.users[user.Id] = user
+	return certification, nil
+}
+
+func (db *inMemoryClient) DeleteCertification(ctx context.Context, userID, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	i := certificationIndex(&user, id)
+	if i < 0 {
+		return domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", id)
+	}
+	user = copyUser(&user)
+	user.Certifications = append(user.Certifications[:i], user.Certifications[i+1:]...)
+	db.users[user.Id] = user
+	return nil
+}
+
+// certificationIndex returns the index of certification id in the
+// certifications of user, or -1.
+func certificationIndex(user *domain.User, id string) int {
+	for i, certification := range user.Certifications {
+		if certification.Id == id {
+			return i
+		}
+	}
+	return -1
+}
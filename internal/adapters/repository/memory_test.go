@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/AntonyIS/portfolio-be/internal/adapters/repository/repositorytest"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+)
+
+func TestInMemoryRepository(t *testing.T) {
+	newRepo := func(t *testing.T) ports.PortfolioRepository {
+		return NewInMemoryRepository()
+	}
+	repositorytest.Run(t, newRepo)
+	repositorytest.RunOrderedPages(t, newRepo)
+}
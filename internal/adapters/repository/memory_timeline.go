@@ -0,0 +1,218 @@
+/*
+Package name : repository
+File name : memory_timeline.go
+Author : Antony Injila
+Description :
+	- Host the in-memory store of experience and education, kept on the user
+	  they belong to
+*/
+
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *inMemoryClient) CreateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[experience.UserID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", experience.UserID)
+	}
+	if experienceIndex(&user, experience.Id) >= 0 {
+		return nil, domain.NewError(domain.ErrConflict, "experience [ %s ] exists", experience.Id)
+	}
+	e := copyExperience(experience)
+	user.Experiences = append(user.Experiences, &e)
+	db.users[user.Id] = copyUser(&user)
+	return experience, nil
+}
+
+func (db *inMemoryClient) ReadExperience(ctx context.Context, userID, id string) (*domain.Experience, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	i := experienceIndex(&user, id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", id)
+	}
+	res := copyExperience(user.Experiences[i])
+	return &res, nil
+}
+
+func (db *inMemoryClient) ReadExperiences(ctx context.Context, userID string) ([]*domain.Experience, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	experiences := []*domain.Experience{}
+	for _, experience := range user.Experiences {
+		e := copyExperience(experience)
+		experiences = append(experiences, &e)
+	}
+	sortExperiences(experiences)
+	return experiences, nil
+}
+
+func (db *inMemoryClient) UpdateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[experience.UserID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", experience.UserID)
+	}
+	i := experienceIndex(&user, experience.Id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", experience.Id)
+	}
+	user = copyUser(&user)
+	e := copyExperience(experience)
+	user.Experiences[i] = &e
+	db.users[user.Id] = user
+	return experience, nil
+}
+
+func (db *inMemoryClient) DeleteExperience(ctx context.Context, userID, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	i := experienceIndex(&user, id)
+	if i < 0 {
+		return domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", id)
+	}
+	user = copyUser(&user)
+	user.Experiences = append(user.Experiences[:i], user.Experiences[i+1:]...)
+	db.users[user.Id] = user
+	return nil
+}
+
+func (db *inMemoryClient) CreateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[education.UserID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", education.UserID)
+	}
+	if educationIndex(&user, education.Id) >= 0 {
+		return nil, domain.NewError(domain.ErrConflict, "education [ %s ] exists", education.Id)
+	}
+	e := *education
+	user.Education = append(user.Education, &e)
+	db.users[user.Id] = copyUser(&user)
+	return education, nil
+}
+
+func (db *inMemoryClient) ReadEducation(ctx context.Context, userID, id string) (*domain.Education, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	i := educationIndex(&user, id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", id)
+	}
+	res := *user.Education[i]
+	return &res, nil
+}
+
+func (db *inMemoryClient) ReadEducations(ctx context.Context, userID string) ([]*domain.Education, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	education := []*domain.Education{}
+	for _, item := range user.Education {
+		e := *item
+		education = append(education, &e)
+	}
+	sortEducation(education)
+	return education, nil
+}
+
+func (db *inMemoryClient) UpdateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[education.UserID]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", education.UserID)
+	}
+	i := educationIndex(&user, education.Id)
+	if i < 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", education.Id)
+	}
+	user = copyUser(&user)
+	e := *education
+	user.Education[i] = &e
+	db.users[user.Id] = user
+	return education, nil
+}
+
+func (db *inMemoryClient) DeleteEducation(ctx context.Context, userID, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	i := educationIndex(&user, id)
+	if i < 0 {
+		return domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", id)
+	}
+	user = copyUser(&user)
+	user.Education = append(user.Education[:i], user.Education[i+1:]...)
+	db.users[user.Id] = user
+	return nil
+}
+
+// experienceIndex returns the index of experience id in the experience of
+// user, or -1.
+func experienceIndex(user *domain.User, id string) int {
+	for i, experience := range user.Experiences {
+		if experience.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// educationIndex returns the index of education id in the education of
+// user, or -1.
+func educationIndex(user *domain.User, id string) int {
+	for i, education := range user.Education {
+		if education.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyExperience(experience *domain.Experience) domain.Experience {
+	res := *experience
+	res.Achievements = append([]string(nil), experience.Achievements...)
+	return res
+}
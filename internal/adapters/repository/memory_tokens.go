@@ -0,0 +1,132 @@
+/*
+Package name : repository
+File name : memory_tokens.go
+Author : Antony Injila
+Description :
+	- Host the in-memory store of revoked, refresh and password reset tokens
+*/
+
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *inMemoryClient) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Forget revocations of tokens that have expired since
+	now := time.Now()
+	for id, expiry := range db.revoked {
+		if !expiry.After(now) {
+			delete(db.revoked, id)
+		}
+	}
+	db.revoked[id] = expiresAt
+	return nil
+}
+
+func (db *inMemoryClient) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	expiresAt, ok := db.revoked[id]
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+func (db *inMemoryClient) CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Forget refresh tokens that have expired
+	now := time.Now().Unix()
+	for id, stored := range db.refreshTokens {
+		if stored.ExpiresAt <= now {
+			delete(db.refreshTokens, id)
+		}
+	}
+	db.refreshTokens[token.Id] = *token
+	return nil
+}
+
+func (db *inMemoryClient) ReadRefreshToken(ctx context.Context, id string) (*domain.RefreshToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	token, ok := db.refreshTokens[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "refresh token not found")
+	}
+	return &token, nil
+}
+
+func (db *inMemoryClient) UseRefreshToken(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	token, ok := db.refreshTokens[id]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "refresh token not found")
+	}
+	if token.Used {
+		return domain.NewError(domain.ErrConflict, "refresh token was used before")
+	}
+	token.Used = true
+	db.refreshTokens[id] = token
+	return nil
+}
+
+func (db *inMemoryClient) DeleteRefreshTokenFamily(ctx context.Context, familyID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, token := range db.refreshTokens {
+		if token.FamilyID == familyID {
+			delete(db.refreshTokens, id)
+		}
+	}
+	return nil
+}
+
+func (db *inMemoryClient) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, token := range db.refreshTokens {
+		if token.UserID == userID {
+			delete(db.refreshTokens, id)
+		}
+	}
+	return nil
+}
+
+func (db *inMemoryClient) CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Forget reset tokens that have expired
+	now := time.Now().Unix()
+	for id, stored := range db.resetTokens {
+		if stored.ExpiresAt <= now {
+			delete(db.resetTokens, id)
+		}
+	}
+	db.resetTokens[token.Id] = *token
+	return nil
+}
+
+func (db *inMemoryClient) ConsumePasswordResetToken(ctx context.Context, id string) (*domain.PasswordResetToken, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	token, ok := db.resetTokens[id]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "password reset token not found")
+	}
+	delete(db.resetTokens, id)
+	return &token, nil
+}
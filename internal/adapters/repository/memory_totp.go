@@ -0,0 +1,51 @@
+/*
+Package name : repository
+File name : memory_totp.go
+Author : Antony Injila
+Description :
+	- Host the in-memory store of used two-factor codes
+*/
+
+package repository
+
+import (
+	"context"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+func (db *inMemoryClient) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	if step <= user.TOTPLastStep {
+		return domain.NewError(domain.ErrConflict, "the code was used before")
+	}
+	user.TOTPLastStep = step
+	db.users[userID] = user
+	return nil
+}
+
+func (db *inMemoryClient) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", userID)
+	}
+	for i, code := range user.RecoveryCodes {
+		if code == hash {
+			// Build a new slice, the stored one may back copies handed out
+			codes := append([]string(nil), user.RecoveryCodes[:i]...)
+			user.RecoveryCodes = append(codes, user.RecoveryCodes[i+1:]...)
+			db.users[userID] = user
+			return nil
+		}
+	}
+	return domain.NewError(domain.ErrConflict, "the recovery code was used before")
+}
@@ -0,0 +1,7 @@
+CREATE TABLE password_reset_tokens (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX password_reset_tokens_expires_at_idx ON password_reset_tokens (expires_at);
@@ -0,0 +1,2 @@
+-- Accounts created before email verification existed are trusted
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+-- Hashes of the unused recovery codes, separated by commas
+ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT '';
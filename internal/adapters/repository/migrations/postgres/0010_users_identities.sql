@@ -0,0 +1,3 @@
+-- Accounts at identity providers linked to the user, as provider:subject
+-- separated by commas
+ALTER TABLE users ADD COLUMN identities TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+CREATE TABLE experiences (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	company TEXT NOT NULL,
+	role TEXT NOT NULL,
+	location TEXT NOT NULL DEFAULT '',
+	start_date TEXT NOT NULL,
+	end_date TEXT NOT NULL DEFAULT '',
+	-- Separated by line breaks
+	achievements TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX experiences_user_id_idx ON experiences (user_id);
+
+CREATE TABLE education (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	institution TEXT NOT NULL,
+	degree TEXT NOT NULL,
+	field_of_study TEXT NOT NULL DEFAULT '',
+	start_date TEXT NOT NULL,
+	end_date TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX education_user_id_idx ON education (user_id);
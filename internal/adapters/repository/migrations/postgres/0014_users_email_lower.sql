@@ -0,0 +1,2 @@
+-- Emails are stored lower case since the service normalizes them
+UPDATE users SET email = LOWER(TRIM(email));
@@ -0,0 +1,37 @@
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	firstname TEXT NOT NULL DEFAULT '',
+	lastname TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	password TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX users_email_idx ON users (email);
+
+CREATE TABLE projects (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	title TEXT NOT NULL DEFAULT '',
+	body TEXT NOT NULL DEFAULT '',
+	user_name TEXT NOT NULL DEFAULT '',
+	user_title TEXT NOT NULL DEFAULT '',
+	rate INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX projects_user_id_idx ON projects (user_id);
+CREATE INDEX projects_created_at_idx ON projects (created_at DESC, id);
+
+CREATE TABLE certifications (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	title TEXT NOT NULL DEFAULT '',
+	institution TEXT NOT NULL DEFAULT '',
+	state TEXT NOT NULL DEFAULT '',
+	issued_date TEXT NOT NULL DEFAULT '',
+	credential_link TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX certifications_user_id_idx ON certifications (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX users_email_idx;
+
+CREATE UNIQUE INDEX users_email_unique ON users (email);
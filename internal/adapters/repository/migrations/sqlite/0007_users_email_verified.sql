@@ -0,0 +1,2 @@
+-- Accounts created before email verification existed are trusted
+ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+CREATE TABLE login_attempts (
+	id TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failure INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX login_attempts_expires_at_idx ON login_attempts (expires_at);
@@ -0,0 +1,12 @@
+CREATE TABLE api_keys (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	-- Separated by commas
+	scopes TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,74 @@
+/*
+Package name : repository
+File name : postgres.go
+Author : Antony Injila
+Description :
+	- Host the PostgreSQL repository constructor
+	- Runs the embedded schema migrations before the repository is used
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	"github.com/lib/pq"
+	errs "github.com/pkg/errors"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresMigrationsLock is the pg_advisory_lock key that keeps instances
+// starting together from running the same migrations at once.
+const postgresMigrationsLock = 7241605382
+
+func NewPostgresRepository(c *config.AppConfig) (ports.PortfolioRepository, error) {
+	db, err := sql.Open("postgres", c.DatabaseURL)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.postgres.NewPostgresRepository")
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, errs.Wrap(err, "adapters.repository.postgres.NewPostgresRepository")
+	}
+	if err = migratePostgres(db); err != nil {
+		db.Close()
+		return nil, errs.Wrap(err, "adapters.repository.postgres.NewPostgresRepository")
+	}
+
+	return &sqlClient{
+		db:           db,
+		bind:         bindDollar,
+		isEmailTaken: isPostgresEmailTaken,
+	}, nil
+}
+
+// migratePostgres runs the migrations while holding an advisory lock.
+// Session locks belong to a connection, so the lock is taken and released
+// on one connection set aside from the pool.
+func migratePostgres(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, postgresMigrationsLock); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, postgresMigrationsLock)
+
+	return migrate(db, bindDollar, postgresMigrations, "migrations/postgres")
+}
+
+func isPostgresEmailTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_unique"
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"os"
+	"testing"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/repository/repositorytest"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+)
+
+// TestPostgresRepository runs the contract suite against the database at
+// POSTGRES_TEST_URL and is skipped when it is not set.
+func TestPostgresRepository(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+	repo, err := NewPostgresRepository(&config.AppConfig{DatabaseURL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRepo := func(t *testing.T) ports.PortfolioRepository {
+		return repo
+	}
+	repositorytest.Run(t, newRepo)
+	repositorytest.RunOrderedPages(t, newRepo)
+}
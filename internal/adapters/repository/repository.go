@@ -0,0 +1,145 @@
+/*
+Package name : repository
+File name : repository.go
+Author : Antony Injila
+Description :
+	- Host helpers shared by the repository adapters
+	- Listings are paged with opaque cursors, every page is ordered the same way
+	  by every adapter. Memory and SQL also keep that order across pages,
+	  DynamoDB pages follow the scan order of the table
+*/
+
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+// sortUsers orders users by id, the order every adapter lists a page of users in.
+func sortUsers(users []*domain.User) {
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+}
+
+// sortProjects orders projects newest first, breaking ties by id.
+func sortProjects(projects []*domain.Project) {
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].CreateAt != projects[j].CreateAt {
+			return projects[i].CreateAt > projects[j].CreateAt
+		}
+		return projects[i].Id < projects[j].Id
+	})
+}
+
+// sortCertifications orders certifications newest first, breaking ties by
+// id. Issued dates are ISO 8601, so they sort as strings.
+func sortCertifications(certifications []*domain.Certification) {
+	sort.Slice(certifications, func(i, j int) bool {
+		if certifications[i].IssuedDate != certifications[j].IssuedDate {
+			return certifications[i].IssuedDate > certifications[j].IssuedDate
+		}
+		return certifications[i].Id < certifications[j].Id
+	})
+}
+
+// sortExperiences orders experience most recent first, see timelineBefore.
+func sortExperiences(experiences []*domain.Experience) {
+	sort.Slice(experiences, func(i, j int) bool {
+		a, b := experiences[i], experiences[j]
+		return timelineBefore(a.StartDate, a.EndDate, a.Id, b.StartDate, b.EndDate, b.Id)
+	})
+}
+
+// sortEducation orders education most recent first, see timelineBefore.
+func sortEducation(education []*domain.Education) {
+	sort.Slice(education, func(i, j int) bool {
+		a, b := education[i], education[j]
+		return timelineBefore(a.StartDate, a.EndDate, a.Id, b.StartDate, b.EndDate, b.Id)
+	})
+}
+
+// timelineBefore reports whether the timeline entry a comes before b. Ongoing
+// entries come first, then entries that ended last, then entries that started
+// last, ties are broken by id. Dates are ISO 8601, so they sort as strings.
+func timelineBefore(aStart, aEnd, aID, bStart, bEnd, bID string) bool {
+	if (aEnd == "") != (bEnd == "") {
+		return aEnd == ""
+	}
+	if aEnd != bEnd {
+		return aEnd > bEnd
+	}
+	if aStart != bStart {
+		return aStart > bStart
+	}
+	return aID < bID
+}
+
+// sortAPIKeys orders keys oldest first, as ReadAPIKeys returns them.
+func sortAPIKeys(keys []*domain.APIKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt != keys[j].CreatedAt {
+			return keys[i].CreatedAt < keys[j].CreatedAt
+		}
+		return keys[i].Id < keys[j].Id
+	})
+}
+
+// withoutCredentials clears the password and two-factor secrets of a user in
+// a listing. Listings never need them, so the DynamoDB adapter doesn't even
+// read them.
+func withoutCredentials(user *domain.User) *domain.User {
+	user.Password = ""
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	return user
+}
+
+// pageCursor is the position of the last item of a page, users only use Id.
+type pageCursor struct {
+	Id       string `json:"id"`
+	CreateAt int64  `json:"created_at,omitempty"`
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the zero cursor, the start of a listing, for "".
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	if s == "" {
+		return c, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, domain.NewError(domain.ErrValidation, "invalid cursor")
+	}
+	if err = json.Unmarshal(data, &c); err != nil || c.Id == "" {
+		return c, domain.NewError(domain.ErrValidation, "invalid cursor")
+	}
+	return c, nil
+}
+
+// afterUser reports whether user comes after the cursor c in the user order.
+func afterUser(user *domain.User, c pageCursor) bool {
+	return c.Id == "" || user.Id > c.Id
+}
+
+// afterProject reports whether project comes after the cursor c in the
+// project order.
+func afterProject(project *domain.Project, c pageCursor) bool {
+	if c.Id == "" {
+		return true
+	}
+	if project.CreateAt != c.CreateAt {
+		return project.CreateAt < c.CreateAt
+	}
+	return project.Id > c.Id
+}
@@ -0,0 +1,1235 @@
+/*
+Package name : repositorytest
+File name : repositorytest.go
+Author : Antony Injila
+Description :
+	- Host the contract test suite every PortfolioRepository adapter must pass
+	- Adapters call Run from their own tests with a factory for a fresh repository
+*/
+
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	"github.com/google/uuid"
+)
+
+// Factory returns a repository for a single sub test. Adapters backed by
+// shared storage may return the same repository every time; the suite only
+// relies on the items it creates itself.
+type Factory func(t *testing.T) ports.PortfolioRepository
+
+// Run verifies that the repository returned by newRepo behaves like every
+// other PortfolioRepository adapter.
+func Run(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("Create and read user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		res, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Id != user.Id || res.Email != user.Email || res.FirstName != user.FirstName || res.LastName != user.LastName || res.Title != user.Title || res.Password != user.Password || res.Role != user.Role {
+			t.Errorf("read user %+v does not match created user %+v", res, user)
+		}
+	})
+
+	t.Run("Read user with unknown id", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.ReadUser(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a user that does not exist returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Read user with email", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		res, err := repo.ReadUserWithEmail(ctx, user.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Id != user.Id {
+			t.Errorf("user with email %s has id %s, want %s", user.Email, res.Id, user.Id)
+		}
+
+		if _, err := repo.ReadUserWithEmail(ctx, uuid.New().String()+"@example.com"); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a user with an unknown email returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Read user with identity", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		subject := uuid.New().String()
+		user.Identities = []string{"github:" + subject + "_1", "google:" + subject}
+		if _, err := repo.UpdateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, key := range user.Identities {
+			res, err := repo.ReadUserWithIdentity(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Id != user.Id {
+				t.Errorf("user with identity %s has id %s, want %s", key, res.Id, user.Id)
+			}
+		}
+		// Keys match whole identities only
+		for _, key := range []string{"github:" + subject + "%1", "github:" + subject, "google:" + subject[:8], "github:" + subject + "_"} {
+			if _, err := repo.ReadUserWithIdentity(ctx, key); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("reading a user with identity %s returned %v, want %v", key, err, domain.ErrNotFound)
+			}
+		}
+	})
+
+	t.Run("Read users", func(t *testing.T) {
+		repo := newRepo(t)
+		created := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			created[newUser(t, repo).Id] = true
+		}
+
+		users, _, err := repo.ReadUsers(ctx, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, user := range users {
+			if created[user.Id] {
+				ids = append(ids, user.Id)
+			}
+			if user.Password != "" || user.TOTPSecret != "" {
+				t.Errorf("listed user %s with credentials", user.Id)
+			}
+		}
+		if len(ids) != len(created) {
+			t.Fatalf("read %d of the %d created users", len(ids), len(created))
+		}
+		// Users are listed by id
+		for i := 1; i < len(ids); i++ {
+			if ids[i-1] > ids[i] {
+				t.Errorf("users are not ordered by id: %v", ids)
+			}
+		}
+	})
+
+	t.Run("Read users in pages", func(t *testing.T) {
+		repo := newRepo(t)
+		created := map[string]bool{}
+		for i := 0; i < 5; i++ {
+			created[newUser(t, repo).Id] = true
+		}
+
+		seen := map[string]bool{}
+		cursor := ""
+		for page := 0; ; page++ {
+			if page > 1000 {
+				t.Fatal("listing users did not end")
+			}
+			users, next, err := repo.ReadUsers(ctx, 2, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) > 2 {
+				t.Errorf("page has %d users, want at most 2", len(users))
+			}
+			// Every page is ordered by id, DynamoDB does not order across pages
+			if len(users) == 2 && users[0].Id > users[1].Id {
+				t.Errorf("page lists user %s before %s", users[0].Id, users[1].Id)
+			}
+			for _, user := range users {
+				if seen[user.Id] {
+					t.Errorf("user %s listed twice", user.Id)
+				}
+				seen[user.Id] = true
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		for id := range created {
+			if !seen[id] {
+				t.Errorf("user %s was not listed", id)
+			}
+		}
+	})
+
+	t.Run("Read users with invalid cursor", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, _, err := repo.ReadUsers(ctx, 2, "not a cursor"); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("reading users with an invalid cursor returned %v, want %v", err, domain.ErrValidation)
+		}
+	})
+
+	t.Run("Update user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		user.FirstName = "John"
+		user.Title = "Rust Software Engineer"
+		user.Role = domain.RoleAdmin
+		user.EmailVerified = true
+		user.TOTPEnabled = true
+		user.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		user.TOTPLastStep = 56000000
+		user.RecoveryCodes = []string{"first", "second"}
+		user.Identities = []string{"github:42"}
+
+		if _, err := repo.UpdateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		res, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.FirstName != user.FirstName || res.Title != user.Title || res.Role != user.Role || !res.EmailVerified {
+			t.Errorf("read user %+v does not match updated user %+v", res, user)
+		}
+		if !res.TOTPEnabled || res.TOTPSecret != user.TOTPSecret || res.TOTPLastStep != user.TOTPLastStep || strings.Join(res.RecoveryCodes, ",") != "first,second" {
+			t.Errorf("read user %+v does not have the two-factor settings of updated user %+v", res, user)
+		}
+		if strings.Join(res.Identities, ",") != "github:42" {
+			t.Errorf("read user has identities %v, want github:42", res.Identities)
+		}
+	})
+
+	t.Run("Update user with unknown id", func(t *testing.T) {
+		repo := newRepo(t)
+		id := uuid.New().String()
+		user := &domain.User{Id: id, Email: id + "@example.com", Password: "password", Role: domain.RoleOwner}
+
+		if _, err := repo.UpdateUser(ctx, user); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("updating a user that does not exist returned %v, want %v", err, domain.ErrNotFound)
+		}
+		// Updates don't create users
+		if _, err := repo.ReadUser(ctx, id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading the user after the update returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.CreateUser(ctx, user); err != nil {
+			t.Errorf("creating a user with the email of the failed update: %v", err)
+		}
+		repo.DeleteUser(ctx, id)
+	})
+
+	t.Run("Update user while its lists change", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		// The profile is edited from a copy read before the lists changed
+		stale, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certification := &domain.Certification{Id: uuid.New().String(), UserID: user.Id, Title: "AWS Certified Developer", Institution: "Amazon Web Services", IssuedDate: "2022-03-01"}
+		if _, err := repo.CreateCertification(ctx, certification); err != nil {
+			t.Fatal(err)
+		}
+		experience := &domain.Experience{Id: uuid.New().String(), UserID: user.Id, Company: "Safaricom", Role: "Backend Engineer", StartDate: "2019-01"}
+		if _, err := repo.CreateExperience(ctx, experience); err != nil {
+			t.Fatal(err)
+		}
+		education := &domain.Education{Id: uuid.New().String(), UserID: user.Id, Institution: "University of Nairobi", Degree: "BSc", StartDate: "2014-09"}
+		if _, err := repo.CreateEducation(ctx, education); err != nil {
+			t.Fatal(err)
+		}
+
+		stale.FirstName = "John"
+		if _, err := repo.UpdateUser(ctx, stale); err != nil {
+			t.Fatal(err)
+		}
+		res, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.FirstName != "John" {
+			t.Errorf("read user has first name %q, want John", res.FirstName)
+		}
+		if len(res.Certifications) != 1 || res.Certifications[0].Id != certification.Id {
+			t.Errorf("read user has certifications %+v, want the one created before the update", res.Certifications)
+		}
+		if len(res.Experiences) != 1 || res.Experiences[0].Id != experience.Id {
+			t.Errorf("read user has experiences %+v, want the one created before the update", res.Experiences)
+		}
+		if len(res.Education) != 1 || res.Education[0].Id != education.Id {
+			t.Errorf("read user has education %+v, want the one created before the update", res.Education)
+		}
+	})
+
+	t.Run("Delete user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		if err := repo.DeleteUser(ctx, user.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadUser(ctx, user.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.ReadUserWithEmail(ctx, user.Email); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted user with email returned %v, want %v", err, domain.ErrNotFound)
+		}
+		// Deleting is idempotent
+		if err := repo.DeleteUser(ctx, user.Id); err != nil {
+			t.Errorf("deleting a deleted user: %v", err)
+		}
+	})
+
+	t.Run("Delete user with projects, API keys and tokens", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		project := newProject(t, repo, user, time.Now().Unix())
+		token := newRefreshToken(t, repo, user, uuid.New().String())
+		key := &domain.APIKey{Id: "pfk_" + uuid.New().String()[:12], UserID: user.Id, Name: "CI", Hash: "hash", Scopes: []string{"projects:write"}, CreatedAt: time.Now().Unix()}
+		if err := repo.CreateAPIKey(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		reset := &domain.PasswordResetToken{Id: uuid.New().String(), UserID: user.Id, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+		if err := repo.CreatePasswordResetToken(ctx, reset); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.DeleteUser(ctx, user.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadProject(ctx, project.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a project of a deleted user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.ReadAPIKey(ctx, key.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading an API key of a deleted user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.ReadRefreshToken(ctx, token.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a refresh token of a deleted user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.ConsumePasswordResetToken(ctx, reset.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("consuming a password reset token of a deleted user returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Create user with taken email", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		other := &domain.User{
+			Id:    uuid.New().String(),
+			Email: user.Email,
+		}
+
+		if _, err := repo.CreateUser(ctx, other); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Errorf("creating a user with a taken email returned %v, want %v", err, domain.ErrEmailTaken)
+			repo.DeleteUser(ctx, other.Id)
+		}
+	})
+
+	t.Run("Update user to taken email", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		other := newUser(t, repo)
+		other.Email = user.Email
+
+		if _, err := repo.UpdateUser(ctx, other); !errors.Is(err, domain.ErrEmailTaken) {
+			t.Errorf("updating a user to a taken email returned %v, want %v", err, domain.ErrEmailTaken)
+		}
+
+		// The email of a deleted user can be taken again
+		if err := repo.DeleteUser(ctx, user.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.UpdateUser(ctx, other); err != nil {
+			t.Errorf("updating a user to the email of a deleted user: %v", err)
+		}
+	})
+
+	t.Run("Use TOTP steps and recovery codes", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		user.TOTPEnabled = true
+		user.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		user.RecoveryCodes = []string{"first", "second", "third"}
+		if _, err := repo.UpdateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.UseTOTPStep(ctx, user.Id, 56000000); err != nil {
+			t.Fatal(err)
+		}
+		// Steps can't be used twice or go back
+		for _, step := range []int64{56000000, 55999999} {
+			if err := repo.UseTOTPStep(ctx, user.Id, step); !errors.Is(err, domain.ErrConflict) {
+				t.Errorf("using step %d after step 56000000 returned %v, want %v", step, err, domain.ErrConflict)
+			}
+		}
+		if err := repo.UseTOTPStep(ctx, uuid.New().String(), 1); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("using a step of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		// Only one of concurrent uses of a recovery code gets through
+		var wg sync.WaitGroup
+		used := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				used <- repo.UseRecoveryCode(ctx, user.Id, "second")
+			}()
+		}
+		wg.Wait()
+		close(used)
+		succeeded := 0
+		for err := range used {
+			if err == nil {
+				succeeded++
+			} else if !errors.Is(err, domain.ErrConflict) {
+				t.Error(err)
+			}
+		}
+		if succeeded != 1 {
+			t.Errorf("recovery code was used %d times, want 1", succeeded)
+		}
+
+		res, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.TOTPLastStep != 56000000 || strings.Join(res.RecoveryCodes, ",") != "first,third" {
+			t.Errorf("user has last step %d and recovery codes %v, want 56000000 and [first third]", res.TOTPLastStep, res.RecoveryCodes)
+		}
+	})
+
+	t.Run("Create and read project", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		project := newProject(t, repo, user, time.Now().UTC().Unix())
+
+		res, err := repo.ReadProject(ctx, project.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *res != *project {
+			t.Errorf("read project %+v does not match created project %+v", res, project)
+		}
+	})
+
+	t.Run("Read project with unknown id", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.ReadProject(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a project that does not exist returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Read projects", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		now := time.Now().UTC().Unix()
+		created := map[string]*domain.Project{}
+		for i := 0; i < 3; i++ {
+			project := newProject(t, repo, user, now+int64(i))
+			created[project.Id] = project
+		}
+
+		projects, _, err := repo.ReadProjects(ctx, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := []*domain.Project{}
+		for _, project := range projects {
+			if want, ok := created[project.Id]; ok {
+				if *project != *want {
+					t.Errorf("listed project %+v does not match created project %+v", project, want)
+				}
+				res = append(res, project)
+			}
+		}
+		if len(res) != len(created) {
+			t.Fatalf("read %d of the %d created projects", len(res), len(created))
+		}
+		// Projects are listed newest first
+		for i := 1; i < len(res); i++ {
+			if res[i-1].CreateAt < res[i].CreateAt {
+				t.Errorf("projects are not ordered newest first: %d before %d", res[i-1].CreateAt, res[i].CreateAt)
+			}
+		}
+	})
+
+	t.Run("Read projects in pages", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		now := time.Now().UTC().Unix()
+		created := map[string]bool{}
+		for i := 0; i < 5; i++ {
+			// Two projects share every timestamp
+			created[newProject(t, repo, user, now+int64(i/2)).Id] = true
+		}
+
+		seen := map[string]bool{}
+		cursor := ""
+		for page := 0; ; page++ {
+			if page > 1000 {
+				t.Fatal("listing projects did not end")
+			}
+			projects, next, err := repo.ReadProjects(ctx, 2, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(projects) > 2 {
+				t.Errorf("page has %d projects, want at most 2", len(projects))
+			}
+			// Every page is ordered newest first, then by id
+			if len(projects) == 2 {
+				a, b := projects[0], projects[1]
+				if a.CreateAt < b.CreateAt || (a.CreateAt == b.CreateAt && a.Id > b.Id) {
+					t.Errorf("page lists project %s before %s", a.Id, b.Id)
+				}
+			}
+			for _, project := range projects {
+				if seen[project.Id] {
+					t.Errorf("project %s listed twice", project.Id)
+				}
+				seen[project.Id] = true
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		for id := range created {
+			if !seen[id] {
+				t.Errorf("project %s was not listed", id)
+			}
+		}
+	})
+
+	t.Run("Update project", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		project := newProject(t, repo, user, time.Now().UTC().Unix())
+		project.Title = "Master gRPC for beginners"
+		project.Rate = 4
+
+		if _, err := repo.UpdateProject(ctx, project); err != nil {
+			t.Fatal(err)
+		}
+		res, err := repo.ReadProject(ctx, project.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *res != *project {
+			t.Errorf("read project %+v does not match updated project %+v", res, project)
+		}
+	})
+
+	t.Run("Delete project", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		project := newProject(t, repo, user, time.Now().UTC().Unix())
+
+		if err := repo.DeleteProject(ctx, project.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadProject(ctx, project.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted project returned %v, want %v", err, domain.ErrNotFound)
+		}
+		// Deleting is idempotent
+		if err := repo.DeleteProject(ctx, project.Id); err != nil {
+			t.Errorf("deleting a deleted project: %v", err)
+		}
+	})
+
+	t.Run("Create, read, update and delete certifications", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		certifications := []*domain.Certification{
+			{Id: uuid.New().String(), UserID: user.Id, Title: "AWS Certified Developer", Institution: "Amazon Web Services", IssuedDate: "2022-03-01", Description: "Associate"},
+			{Id: uuid.New().String(), UserID: user.Id, Title: "Certified Kubernetes Application Developer", Institution: "CNCF", IssuedDate: "2023-06-15"},
+		}
+		for _, certification := range certifications {
+			if _, err := repo.CreateCertification(ctx, certification); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := repo.ReadCertification(ctx, user.Id, certifications[0].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, certifications[0]) {
+			t.Errorf("read certification %+v does not match created certification %+v", res, certifications[0])
+		}
+		listed, err := repo.ReadCertifications(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != 2 || listed[0].Id != certifications[1].Id || listed[1].Id != certifications[0].Id {
+			t.Errorf("listed certifications %+v, want the two certifications newest first", listed)
+		}
+		// Certifications are part of the user
+		read, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Certifications) != 2 {
+			t.Errorf("user has %d certifications, want 2", len(read.Certifications))
+		}
+
+		other := newUser(t, repo)
+		if _, err := repo.ReadCertification(ctx, other.Id, certifications[0].Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading the certification of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if listed, err := repo.ReadCertifications(ctx, other.Id); err != nil || len(listed) != 0 {
+			t.Errorf("listed certifications %+v, %v of a user without any", listed, err)
+		}
+		if _, err := repo.ReadCertifications(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("listing certifications of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.CreateCertification(ctx, &domain.Certification{Id: uuid.New().String(), UserID: uuid.New().String()}); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("creating a certification of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		updated := *certifications[0]
+		updated.Title = "AWS Certified Solutions Architect"
+		updated.CredentialLink = "https://aws.amazon.com/verification"
+		if _, err := repo.UpdateCertification(ctx, &updated); err != nil {
+			t.Fatal(err)
+		}
+		res, err = repo.ReadCertification(ctx, user.Id, updated.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, &updated) {
+			t.Errorf("read certification %+v does not match updated certification %+v", res, updated)
+		}
+		moved := updated
+		moved.UserID = other.Id
+		if _, err := repo.UpdateCertification(ctx, &moved); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("updating the certification of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		if err := repo.DeleteCertification(ctx, other.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting the certification of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if err := repo.DeleteCertification(ctx, user.Id, updated.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadCertification(ctx, user.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted certification returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if err := repo.DeleteCertification(ctx, user.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting a deleted certification returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if listed, err := repo.ReadCertifications(ctx, user.Id); err != nil || len(listed) != 1 {
+			t.Errorf("listed certifications %+v, %v after deleting one of two", listed, err)
+		}
+	})
+
+	t.Run("Create certifications concurrently", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		// Adapters may refuse a change that raced with others, but must not
+		// lose one they accepted
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = repo.CreateCertification(ctx, &domain.Certification{Id: uuid.New().String(), UserID: user.Id, Title: "CKAD"})
+			}(i)
+		}
+		wg.Wait()
+		created := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, domain.ErrConflict):
+				t.Errorf("creating certifications concurrently: %v", err)
+			}
+		}
+		listed, err := repo.ReadCertifications(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != created {
+			t.Errorf("listed %d certifications after creating %d", len(listed), created)
+		}
+	})
+
+	t.Run("Create, read, update and delete experience", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		experiences := []*domain.Experience{
+			{Id: uuid.New().String(), UserID: user.Id, Company: "Safaricom", Role: "Backend Engineer", StartDate: "2019-01", EndDate: "2021-08", Achievements: []string{"Moved billing to Go", "Cut p99 latency in half"}},
+			{Id: uuid.New().String(), UserID: user.Id, Company: "Andela", Role: "Software Engineer", Location: "Remote", StartDate: "2021-09"},
+			{Id: uuid.New().String(), UserID: user.Id, Company: "Freelance", Role: "Developer", StartDate: "2018-03", EndDate: "2018-12"},
+		}
+		for _, experience := range experiences {
+			if _, err := repo.CreateExperience(ctx, experience); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := repo.ReadExperience(ctx, user.Id, experiences[0].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, experiences[0]) {
+			t.Errorf("read experience %+v does not match created experience %+v", res, experiences[0])
+		}
+		listed, err := repo.ReadExperiences(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The ongoing job comes first, then the others by end date
+		if len(listed) != 3 || listed[0].Id != experiences[1].Id || listed[1].Id != experiences[0].Id || listed[2].Id != experiences[2].Id {
+			t.Errorf("listed experiences %+v, want the ongoing job first and the rest most recent first", listed)
+		}
+		read, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Experiences) != 3 {
+			t.Errorf("user has %d experiences, want 3", len(read.Experiences))
+		}
+
+		other := newUser(t, repo)
+		if _, err := repo.ReadExperience(ctx, other.Id, experiences[0].Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading the experience of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if listed, err := repo.ReadExperiences(ctx, other.Id); err != nil || len(listed) != 0 {
+			t.Errorf("listed experiences %+v, %v of a user without any", listed, err)
+		}
+		if _, err := repo.ReadExperiences(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("listing experiences of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.CreateExperience(ctx, &domain.Experience{Id: uuid.New().String(), UserID: uuid.New().String()}); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("creating an experience of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		updated := *experiences[1]
+		updated.EndDate = "2024-02"
+		updated.Achievements = []string{"Led the payments team"}
+		if _, err := repo.UpdateExperience(ctx, &updated); err != nil {
+			t.Fatal(err)
+		}
+		res, err = repo.ReadExperience(ctx, user.Id, updated.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, &updated) {
+			t.Errorf("read experience %+v does not match updated experience %+v", res, updated)
+		}
+		moved := updated
+		moved.UserID = other.Id
+		if _, err := repo.UpdateExperience(ctx, &moved); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("updating the experience of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		if err := repo.DeleteExperience(ctx, other.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting the experience of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if err := repo.DeleteExperience(ctx, user.Id, updated.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadExperience(ctx, user.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted experience returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if err := repo.DeleteExperience(ctx, user.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting a deleted experience returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if listed, err := repo.ReadExperiences(ctx, user.Id); err != nil || len(listed) != 2 {
+			t.Errorf("listed experiences %+v, %v after deleting one of three", listed, err)
+		}
+	})
+
+	t.Run("Create, read, update and delete education", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+
+		education := []*domain.Education{
+			{Id: uuid.New().String(), UserID: user.Id, Institution: "University of Nairobi", Degree: "BSc", FieldOfStudy: "Computer Science", StartDate: "2014-09", EndDate: "2018-06"},
+			{Id: uuid.New().String(), UserID: user.Id, Institution: "Open University", Degree: "MSc", FieldOfStudy: "Distributed Systems", StartDate: "2022-01", Description: "Part time"},
+		}
+		for _, e := range education {
+			if _, err := repo.CreateEducation(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := repo.ReadEducation(ctx, user.Id, education[0].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, education[0]) {
+			t.Errorf("read education %+v does not match created education %+v", res, education[0])
+		}
+		listed, err := repo.ReadEducations(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != 2 || listed[0].Id != education[1].Id || listed[1].Id != education[0].Id {
+			t.Errorf("listed education %+v, want the ongoing degree first", listed)
+		}
+		read, err := repo.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Education) != 2 {
+			t.Errorf("user has %d education entries, want 2", len(read.Education))
+		}
+
+		other := newUser(t, repo)
+		if _, err := repo.ReadEducation(ctx, other.Id, education[0].Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading the education of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if listed, err := repo.ReadEducations(ctx, other.Id); err != nil || len(listed) != 0 {
+			t.Errorf("listed education %+v, %v of a user without any", listed, err)
+		}
+		if _, err := repo.ReadEducations(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("listing education of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if _, err := repo.CreateEducation(ctx, &domain.Education{Id: uuid.New().String(), UserID: uuid.New().String()}); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("creating education of an unknown user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		updated := *education[0]
+		updated.Degree = "BSc (Hons)"
+		if _, err := repo.UpdateEducation(ctx, &updated); err != nil {
+			t.Fatal(err)
+		}
+		res, err = repo.ReadEducation(ctx, user.Id, updated.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, &updated) {
+			t.Errorf("read education %+v does not match updated education %+v", res, updated)
+		}
+		moved := updated
+		moved.UserID = other.Id
+		if _, err := repo.UpdateEducation(ctx, &moved); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("updating the education of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+
+		if err := repo.DeleteEducation(ctx, other.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting the education of another user returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if err := repo.DeleteEducation(ctx, user.Id, updated.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadEducation(ctx, user.Id, updated.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading deleted education returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if listed, err := repo.ReadEducations(ctx, user.Id); err != nil || len(listed) != 1 {
+			t.Errorf("listed education %+v, %v after deleting one of two", listed, err)
+		}
+	})
+
+	t.Run("Revoke token", func(t *testing.T) {
+		repo := newRepo(t)
+		id := uuid.New().String()
+
+		revoked, err := repo.IsTokenRevoked(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked {
+			t.Errorf("token %s is revoked before it was revoked", id)
+		}
+
+		if err := repo.RevokeToken(ctx, id, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, err = repo.IsTokenRevoked(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if !revoked {
+			t.Errorf("token %s is not revoked", id)
+		}
+		// Revoking is idempotent
+		if err := repo.RevokeToken(ctx, id, time.Now().Add(time.Hour)); err != nil {
+			t.Errorf("revoking a revoked token: %v", err)
+		}
+	})
+
+	t.Run("Revoke expired token", func(t *testing.T) {
+		repo := newRepo(t)
+		id := uuid.New().String()
+
+		// The token can't be used anyway, so the revocation is not kept
+		if err := repo.RevokeToken(ctx, id, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		revoked, err := repo.IsTokenRevoked(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked {
+			t.Errorf("revocation of expired token %s is kept", id)
+		}
+	})
+
+	t.Run("Create and read refresh token", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		token := newRefreshToken(t, repo, user, uuid.New().String())
+
+		res, err := repo.ReadRefreshToken(ctx, token.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *res != *token {
+			t.Errorf("read refresh token %+v does not match created token %+v", res, token)
+		}
+
+		if _, err := repo.ReadRefreshToken(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a refresh token that does not exist returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Use refresh token", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		token := newRefreshToken(t, repo, user, uuid.New().String())
+
+		if err := repo.UseRefreshToken(ctx, token.Id); err != nil {
+			t.Fatal(err)
+		}
+		res, err := repo.ReadRefreshToken(ctx, token.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Used {
+			t.Errorf("refresh token %s is not used", token.Id)
+		}
+		if err := repo.UseRefreshToken(ctx, token.Id); !errors.Is(err, domain.ErrConflict) {
+			t.Errorf("using a used refresh token returned %v, want %v", err, domain.ErrConflict)
+		}
+		if err := repo.UseRefreshToken(ctx, uuid.New().String()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("using a refresh token that does not exist returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Delete refresh token family", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		family := uuid.New().String()
+		first := newRefreshToken(t, repo, user, family)
+		second := newRefreshToken(t, repo, user, family)
+		other := newRefreshToken(t, repo, user, uuid.New().String())
+
+		if err := repo.DeleteRefreshTokenFamily(ctx, family); err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range []*domain.RefreshToken{first, second} {
+			if _, err := repo.ReadRefreshToken(ctx, token.Id); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("reading a refresh token of a deleted family returned %v, want %v", err, domain.ErrNotFound)
+			}
+		}
+		if _, err := repo.ReadRefreshToken(ctx, other.Id); err != nil {
+			t.Errorf("reading a refresh token of another family: %v", err)
+		}
+	})
+
+	t.Run("Delete the refresh tokens of a user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		first := newRefreshToken(t, repo, user, uuid.New().String())
+		second := newRefreshToken(t, repo, user, uuid.New().String())
+		other := newRefreshToken(t, repo, newUser(t, repo), uuid.New().String())
+
+		if err := repo.DeleteUserRefreshTokens(ctx, user.Id); err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range []*domain.RefreshToken{first, second} {
+			if _, err := repo.ReadRefreshToken(ctx, token.Id); !errors.Is(err, domain.ErrNotFound) {
+				t.Errorf("reading a deleted refresh token of the user returned %v, want %v", err, domain.ErrNotFound)
+			}
+		}
+		if _, err := repo.ReadRefreshToken(ctx, other.Id); err != nil {
+			t.Errorf("reading a refresh token of another user: %v", err)
+		}
+	})
+
+	t.Run("Consume password reset token", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		token := &domain.PasswordResetToken{
+			Id:        uuid.New().String(),
+			UserID:    user.Id,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		}
+		if err := repo.CreatePasswordResetToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := repo.ConsumePasswordResetToken(ctx, token.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *res != *token {
+			t.Errorf("consumed password reset token %+v does not match created token %+v", res, token)
+		}
+		// Tokens can be used once
+		if _, err := repo.ConsumePasswordResetToken(ctx, token.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("consuming a consumed password reset token returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Create, list and delete API keys", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		now := time.Now().Unix()
+
+		keys := []*domain.APIKey{
+			{Id: "pfk_" + uuid.New().String()[:12], UserID: user.Id, Name: "CI", Hash: "hash", Scopes: []string{"projects:write", "projects:delete"}, CreatedAt: now},
+			{Id: "pfk_" + uuid.New().String()[:12], UserID: user.Id, Name: "Deploy", Hash: "other hash", Scopes: []string{"projects:write"}, CreatedAt: now + 1, ExpiresAt: now + 3600},
+		}
+		for _, key := range keys {
+			if err := repo.CreateAPIKey(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			defer repo.DeleteAPIKey(ctx, key.Id)
+		}
+		res, err := repo.ReadAPIKey(ctx, keys[0].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.UserID != user.Id || res.Name != "CI" || res.Hash != "hash" || strings.Join(res.Scopes, ",") != "projects:write,projects:delete" || res.CreatedAt != now || res.ExpiresAt != 0 {
+			t.Errorf("read API key %+v does not match created key %+v", res, keys[0])
+		}
+
+		listed, err := repo.ReadAPIKeys(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != 2 || listed[0].Id != keys[0].Id || listed[1].Id != keys[1].Id || listed[1].ExpiresAt != now+3600 {
+			t.Errorf("listed API keys %+v, want the two keys oldest first", listed)
+		}
+
+		if err := repo.DeleteAPIKey(ctx, keys[0].Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadAPIKey(ctx, keys[0].Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted API key returned %v, want %v", err, domain.ErrNotFound)
+		}
+		if err := repo.DeleteAPIKey(ctx, keys[0].Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting a deleted API key returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Record login failures", func(t *testing.T) {
+		repo := newRepo(t)
+		key := "account#" + uuid.New().String()
+		defer repo.ResetLoginAttempts(ctx, key)
+		now := time.Now()
+
+		for want := 1; want <= 3; want++ {
+			res, err := repo.RecordLoginFailure(ctx, key, now, now.Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Failures != want || res.LastFailure != now.Unix() {
+				t.Errorf("recorded login failures %+v, want %d failures", res, want)
+			}
+		}
+		res, err := repo.ReadLoginAttempts(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Failures != 3 || res.ExpiresAt != now.Add(time.Hour).Unix() {
+			t.Errorf("read login attempts %+v, want 3 failures", res)
+		}
+
+		if err := repo.ResetLoginAttempts(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadLoginAttempts(ctx, key); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading reset login attempts returned %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("Record login failures after the count expired", func(t *testing.T) {
+		repo := newRepo(t)
+		key := "address#" + uuid.New().String()
+		defer repo.ResetLoginAttempts(ctx, key)
+		now := time.Now()
+
+		if _, err := repo.RecordLoginFailure(ctx, key, now.Add(-2*time.Hour), now.Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.ReadLoginAttempts(ctx, key); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading expired login attempts returned %v, want %v", err, domain.ErrNotFound)
+		}
+		res, err := repo.RecordLoginFailure(ctx, key, now, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Failures != 1 {
+			t.Errorf("recorded %d failures after the count expired, want 1", res.Failures)
+		}
+	})
+}
+
+// newUser stores a user with a unique id and email and removes it when the
+// test finishes.
+// RunOrderedPages verifies that walking the pages of users and projects
+// keeps the order of a single listing. Adapters whose pages follow an
+// unordered scan, such as DynamoDB, only pass Run.
+func RunOrderedPages(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("Read users in ordered pages", func(t *testing.T) {
+		repo := newRepo(t)
+		created := map[string]bool{}
+		for i := 0; i < 7; i++ {
+			created[newUser(t, repo).Id] = true
+		}
+
+		ids := []string{}
+		cursor := ""
+		for page := 0; ; page++ {
+			if page > 1000 {
+				t.Fatal("listing users did not end")
+			}
+			users, next, err := repo.ReadUsers(ctx, 2, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, user := range users {
+				if created[user.Id] {
+					ids = append(ids, user.Id)
+				}
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if len(ids) != len(created) {
+			t.Fatalf("listed %d of the %d created users", len(ids), len(created))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i-1] >= ids[i] {
+				t.Errorf("pages are not ordered by id: %v", ids)
+				break
+			}
+		}
+	})
+
+	t.Run("Read projects in ordered pages", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser(t, repo)
+		now := time.Now().UTC().Unix()
+		created := map[string]bool{}
+		for i := 0; i < 7; i++ {
+			// Two projects share most timestamps
+			created[newProject(t, repo, user, now+int64(i/2)).Id] = true
+		}
+
+		projects := []*domain.Project{}
+		cursor := ""
+		for page := 0; ; page++ {
+			if page > 1000 {
+				t.Fatal("listing projects did not end")
+			}
+			res, next, err := repo.ReadProjects(ctx, 2, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, project := range res {
+				if created[project.Id] {
+					projects = append(projects, project)
+				}
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if len(projects) != len(created) {
+			t.Fatalf("listed %d of the %d created projects", len(projects), len(created))
+		}
+		for i := 1; i < len(projects); i++ {
+			a, b := projects[i-1], projects[i]
+			if a.CreateAt < b.CreateAt || (a.CreateAt == b.CreateAt && a.Id > b.Id) {
+				t.Errorf("pages list project %s before %s", a.Id, b.Id)
+			}
+		}
+	})
+}
+
+func newUser(t *testing.T, repo ports.PortfolioRepository) *domain.User {
+	t.Helper()
+	ctx := context.Background()
+	id := uuid.New().String()
+	user := &domain.User{
+		Id:        id,
+		FirstName: "Antony",
+		LastName:  "Injila",
+		Email:     id + "@example.com",
+		Title:     "Golang Software Engineer",
+		Password:  "password",
+		Role:      domain.RoleOwner,
+	}
+	if _, err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		repo.DeleteUser(ctx, id)
+	})
+	return user
+}
+
+// newRefreshToken stores an unused refresh token of user in family and
+// removes the family when the test finishes.
+func newRefreshToken(t *testing.T, repo ports.PortfolioRepository, user *domain.User, family string) *domain.RefreshToken {
+	t.Helper()
+	ctx := context.Background()
+	token := &domain.RefreshToken{
+		Id:        uuid.New().String(),
+		FamilyID:  family,
+		UserID:    user.Id,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}
+	if err := repo.CreateRefreshToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		repo.DeleteRefreshTokenFamily(ctx, family)
+	})
+	return token
+}
+
+// newProject stores a project owned by user and removes it when the test
+// finishes.
+func newProject(t *testing.T, repo ports.PortfolioRepository, user *domain.User, createdAt int64) *domain.Project {
+	t.Helper()
+	ctx := context.Background()
+	project := &domain.Project{
+		Id:        uuid.New().String(),
+		UserID:    user.Id,
+		Title:     "Go gRPC for beginners",
+		Body:      "This tutorial provides a basic Go programmer’s introduction to working with gRPC.",
+		UserName:  user.FirstName + " " + user.LastName,
+		UserTitle: user.Title,
+		Rate:      5,
+		CreateAt:  createdAt,
+	}
+	if _, err := repo.CreateProject(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		repo.DeleteProject(ctx, project.Id)
+	})
+	return project
+}
@@ -0,0 +1,392 @@
+/*
+Package name : repository
+File name : sql.go
+Author : Antony Injila
+Description :
+	- Host the database/sql implementation of the portfolio repository
+	- Queries are written with ? placeholders and rebound per driver
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+type sqlClient struct {
+	db   *sql.DB
+	bind func(string) string
+	// isEmailTaken reports whether err violates the unique index on users.email
+	isEmailTaken func(error) bool
+}
+
+// bindQuestion leaves ? placeholders untouched for SQLite.
+func bindQuestion(query string) string {
+	return query
+}
+
+// bindDollar rewrites ? placeholders into $1, $2, ... for Postgres.
+func bindDollar(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const (
+	userColumns          = "id, firstname, lastname, email, title, password, role, email_verified, totp_enabled, totp_secret, totp_last_step, recovery_codes, identities"
+	projectColumns       = "id, user_id, title, body, user_name, user_title, rate, created_at"
+	certificationColumns = "id, user_id, title, institution, state, issued_date, credential_link, description"
+)
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	var user domain.User
+	var recoveryCodes, identities string
+	err := row.Scan(&user.Id, &user.FirstName, &user.LastName, &user.Email, &user.Title, &user.Password, &user.Role, &user.EmailVerified,
+		&user.TOTPEnabled, &user.TOTPSecret, &user.TOTPLastStep, &recoveryCodes, &identities)
+	if err != nil {
+		return nil, err
+	}
+	if recoveryCodes != "" {
+		user.RecoveryCodes = strings.Split(recoveryCodes, ",")
+	}
+	if identities != "" {
+		user.Identities = strings.Split(identities, ",")
+	}
+	return &user, nil
+}
+
+func scanProject(row rowScanner) (*domain.Project, error) {
+	var project domain.Project
+	err := row.Scan(&project.Id, &project.UserID, &project.Title, &project.Body, &project.UserName, &project.UserTitle, &project.Rate, &project.CreateAt)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func scanCertification(row rowScanner) (*domain.Certification, error) {
+	var certification domain.Certification
+	err := row.Scan(&certification.Id, &certification.UserID, &certification.Title, &certification.Institution, &certification.State, &certification.IssuedDate, &certification.CredentialLink, &certification.Description)
+	if err != nil {
+		return nil, err
+	}
+	return &certification, nil
+}
+
+func (db *sqlClient) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateUser")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, db.bind(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		user.Id, user.FirstName, user.LastName, user.Email, user.Title, user.Password, user.Role, user.EmailVerified,
+		user.TOTPEnabled, user.TOTPSecret, user.TOTPLastStep, strings.Join(user.RecoveryCodes, ","), strings.Join(user.Identities, ","))
+	if db.isEmailTaken(err) {
+		return nil, domain.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateUser")
+	}
+	if err = db.writeCertifications(ctx, tx, user); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateUser")
+	}
+	if err = db.writeTimeline(ctx, tx, user); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateUser")
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateUser")
+	}
+	return user, nil
+}
+
+func (db *sqlClient) ReadUser(ctx context.Context, id string) (*domain.User, error) {
+	row := db.db.QueryRowContext(ctx, db.bind(`SELECT `+userColumns+` FROM users WHERE id = ?`), id)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", id)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadUser")
+	}
+	if err = db.loadUserItems(ctx, []*domain.User{user}); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadUser")
+	}
+	return user, nil
+}
+
+func (db *sqlClient) ReadUserWithEmail(ctx context.Context, email string) (*domain.User, error) {
+	row := db.db.QueryRowContext(ctx, db.bind(`SELECT `+userColumns+` FROM users WHERE email = ?`), email)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "user with email [ %s ] not found", email)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadUserWithEmail")
+	}
+	if err = db.loadUserItems(ctx, []*domain.User{user}); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadUserWithEmail")
+	}
+	return user, nil
+}
+
+func (db *sqlClient) ReadUserWithIdentity(ctx context.Context, key string) (*domain.User, error) {
+	// Identities are stored comma separated, the commas around the column
+	// make the key match whole entries only
+	pattern := "%," + likeEscaper.Replace(key) + ",%"
+	row := db.db.QueryRowContext(ctx, db.bind(`SELECT `+userColumns+` FROM users WHERE ',' || identities || ',' LIKE ? ESCAPE '\'`), pattern)
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "user with identity [ %s ] not found", key)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadUserWithIdentity")
+	}
+	if err = db.loadUserItems(ctx, []*domain.User{user}); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadUserWithIdentity")
+	}
+	return user, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with \ as the escape
+// character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (db *sqlClient) ReadUsers(ctx context.Context, limit int, cursor string) ([]*domain.User, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query, args := `SELECT `+userColumns+` FROM users WHERE id > ? ORDER BY id`, []interface{}{c.Id}
+	if limit > 0 {
+		// Read one more row to tell whether there is a next page
+		query, args = query+` LIMIT ?`, append(args, limit+1)
+	}
+	rows, err := db.db.QueryContext(ctx, db.bind(query), args...)
+	if err != nil {
+		return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadUsers")
+	}
+	defer rows.Close()
+
+	users := []*domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadUsers")
+		}
+		users = append(users, withoutCredentials(user))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadUsers")
+	}
+	rows.Close()
+
+	next := ""
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+		next = encodeCursor(pageCursor{Id: users[limit-1].Id})
+	}
+
+	if err = db.loadUserItems(ctx, users); err != nil {
+		return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadUsers")
+	}
+	return users, next, nil
+}
+
+func (db *sqlClient) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	// Certifications, experience and education have their own rows and
+	// methods, the update leaves them alone
+	result, err := db.db.ExecContext(ctx, db.bind(`UPDATE users SET firstname = ?, lastname = ?, email = ?, title = ?, password = ?, role = ?, email_verified = ?,
+		totp_enabled = ?, totp_secret = ?, totp_last_step = ?, recovery_codes = ?, identities = ? WHERE id = ?`),
+		user.FirstName, user.LastName, user.Email, user.Title, user.Password, user.Role, user.EmailVerified,
+		user.TOTPEnabled, user.TOTPSecret, user.TOTPLastStep, strings.Join(user.RecoveryCodes, ","), strings.Join(user.Identities, ","), user.Id)
+	if db.isEmailTaken(err) {
+		return nil, domain.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.UpdateUser")
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.UpdateUser")
+	} else if n == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", user.Id)
+	}
+	return user, nil
+}
+
+func (db *sqlClient) DeleteUser(ctx context.Context, id string) error {
+	// Projects, certifications and the timeline are removed by ON DELETE CASCADE
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM users WHERE id = ?`), id)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteUser")
+	}
+	return nil
+}
+
+func (db *sqlClient) CreateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
+	_, err := db.db.ExecContext(ctx, db.bind(`INSERT INTO projects (`+projectColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		project.Id, project.UserID, project.Title, project.Body, project.UserName, project.UserTitle, project.Rate, project.CreateAt)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateProject")
+	}
+	return project, nil
+}
+
+func (db *sqlClient) ReadProject(ctx context.Context, id string) (*domain.Project, error) {
+	row := db.db.QueryRowContext(ctx, db.bind(`SELECT `+projectColumns+` FROM projects WHERE id = ?`), id)
+	project, err := scanProject(row)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "project with id [ %s ] not found", id)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadProject")
+	}
+	return project, nil
+}
+
+func (db *sqlClient) ReadProjects(ctx context.Context, limit int, cursor string) ([]*domain.Project, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query, args := `SELECT `+projectColumns+` FROM projects`, []interface{}{}
+	if c.Id != "" {
+		query, args = query+` WHERE created_at < ? OR (created_at = ? AND id > ?)`, append(args, c.CreateAt, c.CreateAt, c.Id)
+	}
+	query += ` ORDER BY created_at DESC, id`
+	if limit > 0 {
+		// Read one more row to tell whether there is a next page
+		query, args = query+` LIMIT ?`, append(args, limit+1)
+	}
+	rows, err := db.db.QueryContext(ctx, db.bind(query), args...)
+	if err != nil {
+		return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadProjects")
+	}
+	defer rows.Close()
+
+	projects := []*domain.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadProjects")
+		}
+		projects = append(projects, project)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", errs.Wrap(err, "adapters.repository.sql.ReadProjects")
+	}
+
+	next := ""
+	if limit > 0 && len(projects) > limit {
+		projects = projects[:limit]
+		last := projects[limit-1]
+		next = encodeCursor(pageCursor{Id: last.Id, CreateAt: last.CreateAt})
+	}
+	return projects, next, nil
+}
+
+func (db *sqlClient) UpdateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
+	_, err := db.db.ExecContext(ctx, db.bind(`UPDATE projects SET user_id = ?, title = ?, body = ?, user_name = ?, user_title = ?, rate = ?, created_at = ? WHERE id = ?`),
+		project.UserID, project.Title, project.Body, project.UserName, project.UserTitle, project.Rate, project.CreateAt, project.Id)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.UpdateProject")
+	}
+	return project, nil
+}
+
+func (db *sqlClient) DeleteProject(ctx context.Context, id string) error {
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM projects WHERE id = ?`), id)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteProject")
+	}
+	return nil
+}
+
+// writeCertifications inserts the certifications embedded in user.
+func (db *sqlClient) writeCertifications(ctx context.Context, tx *sql.Tx, user *domain.User) error {
+	for _, c := range user.Certifications {
+		_, err := tx.ExecContext(ctx, db.bind(`INSERT INTO certifications (`+certificationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			c.Id, user.Id, c.Title, c.Institution, c.State, c.IssuedDate, c.CredentialLink, c.Description)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadUserItems fills in the projects, certifications and timeline of users,
+// which live in their own tables.
+func (db *sqlClient) loadUserItems(ctx context.Context, users []*domain.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	byID := map[string]*domain.User{}
+	for _, user := range users {
+		byID[user.Id] = user
+	}
+	where, args := "WHERE user_id IN (?"+strings.Repeat(", ?", len(users)-1)+")", []interface{}{}
+	for _, user := range users {
+		args = append(args, user.Id)
+	}
+
+	rows, err := db.db.QueryContext(ctx, db.bind(`SELECT `+projectColumns+` FROM projects `+where+` ORDER BY created_at DESC, id`), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return err
+		}
+		if user, ok := byID[project.UserID]; ok {
+			user.Projects = append(user.Projects, project)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = db.db.QueryContext(ctx, db.bind(`SELECT `+certificationColumns+` FROM certifications `+where+` ORDER BY issued_date DESC, id`), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		certification, err := scanCertification(rows)
+		if err != nil {
+			return err
+		}
+		if user, ok := byID[certification.UserID]; ok {
+			user.Certifications = append(user.Certifications, certification)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	return db.loadTimeline(ctx, byID, where, args)
+}
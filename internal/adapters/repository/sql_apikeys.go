@@ -0,0 +1,90 @@
+/*
+Package name : repository
+File name : sql_apikeys.go
+Author : Antony Injila
+Description :
+	- Host the database/sql store of API keys
+	- Keys are deleted with the user they belong to
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+const apiKeyColumns = "id, user_id, name, hash, scopes, created_at, expires_at"
+
+func scanAPIKey(row rowScanner) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var scopes string
+	err := row.Scan(&key.Id, &key.UserID, &key.Name, &key.Hash, &scopes, &key.CreatedAt, &key.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	return &key, nil
+}
+
+func (db *sqlClient) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	_, err := db.db.ExecContext(ctx, db.bind(`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		key.Id, key.UserID, key.Name, key.Hash, strings.Join(key.Scopes, ","), key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.CreateAPIKey")
+	}
+	return nil
+}
+
+func (db *sqlClient) ReadAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	key, err := scanAPIKey(db.db.QueryRowContext(ctx, db.bind(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`), id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadAPIKey")
+	}
+	return key, nil
+}
+
+func (db *sqlClient) ReadAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	rows, err := db.db.QueryContext(ctx, db.bind(`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = ? ORDER BY created_at, id`), userID)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadAPIKeys")
+	}
+	defer rows.Close()
+
+	keys := []*domain.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errs.Wrap(err, "adapters.repository.sql.ReadAPIKeys")
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadAPIKeys")
+	}
+	return keys, nil
+}
+
+func (db *sqlClient) DeleteAPIKey(ctx context.Context, id string) error {
+	res, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM api_keys WHERE id = ?`), id)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteAPIKey")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteAPIKey")
+	}
+	if n == 0 {
+		return domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	return nil
+}
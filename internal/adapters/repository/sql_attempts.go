@@ -0,0 +1,58 @@
+/*
+Package name : repository
+File name : sql_attempts.go
+Author : Antony Injila
+Description :
+	- Host the database/sql count of failed logins
+	- Expired counts are removed whenever a failure is recorded
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+func (db *sqlClient) RecordLoginFailure(ctx context.Context, key string, now, expiresAt time.Time) (*domain.LoginAttempts, error) {
+	// Forget counts that have expired, so an expired count starts over
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM login_attempts WHERE expires_at <= ?`), now.Unix())
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.RecordLoginFailure")
+	}
+	// Concurrent failures each add one to the row
+	attempts := domain.LoginAttempts{Key: key}
+	err = db.db.QueryRowContext(ctx, db.bind(`INSERT INTO login_attempts (id, failures, last_failure, expires_at) VALUES (?, 1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET failures = login_attempts.failures + 1, last_failure = excluded.last_failure, expires_at = excluded.expires_at
+		RETURNING failures, last_failure, expires_at`), key, now.Unix(), expiresAt.Unix()).
+		Scan(&attempts.Failures, &attempts.LastFailure, &attempts.ExpiresAt)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.RecordLoginFailure")
+	}
+	return &attempts, nil
+}
+
+func (db *sqlClient) ReadLoginAttempts(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	attempts := domain.LoginAttempts{Key: key}
+	err := db.db.QueryRowContext(ctx, db.bind(`SELECT failures, last_failure, expires_at FROM login_attempts WHERE id = ? AND expires_at > ?`),
+		key, time.Now().Unix()).Scan(&attempts.Failures, &attempts.LastFailure, &attempts.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "no failed logins for %s", key)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadLoginAttempts")
+	}
+	return &attempts, nil
+}
+
+func (db *sqlClient) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM login_attempts WHERE id = ?`), key)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.ResetLoginAttempts")
+	}
+	return nil
+}
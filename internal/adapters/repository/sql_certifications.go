@@ -0,0 +1,106 @@
+/*
+Package name : repository
+File name : sql_certifications.go
+Author : Antony Injila
+Description :
+	- Host the database/sql store of certifications
+	- Certifications are deleted with the user they belong to
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+func (db *sqlClient) CreateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	c := certification
+	// Only insert the certification if its user exists
+	res, err := db.db.ExecContext(ctx, db.bind(`INSERT INTO certifications (`+certificationColumns+`) SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM users WHERE id = ?)`),
+		c.Id, c.UserID, c.Title, c.Institution, c.State, c.IssuedDate, c.CredentialLink, c.Description, c.UserID)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateCertification")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.CreateCertification")
+	}
+	if n == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", c.UserID)
+	}
+	return certification, nil
+}
+
+func (db *sqlClient) ReadCertification(ctx context.Context, userID, id string) (*domain.Certification, error) {
+	certification, err := scanCertification(db.db.QueryRowContext(ctx, db.bind(`SELECT `+certificationColumns+` FROM certifications WHERE id = ? AND user_id = ?`), id, userID))
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", id)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadCertification")
+	}
+	return certification, nil
+}
+
+func (db *sqlClient) ReadCertifications(ctx context.Context, userID string) ([]*domain.Certification, error) {
+	rows, err := db.db.QueryContext(ctx, db.bind(`SELECT `+certificationColumns+` FROM certifications WHERE user_id = ? ORDER BY issued_date DESC, id`), userID)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadCertifications")
+	}
+	defer rows.Close()
+
+	certifications := []*domain.Certification{}
+	for rows.Next() {
+		certification, err := scanCertification(rows)
+		if err != nil {
+			return nil, errs.Wrap(err, "adapters.repository.sql.ReadCertifications")
+		}
+		certifications = append(certifications, certification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadCertifications")
+	}
+	if len(certifications) == 0 {
+		// Tell users without certifications from missing users
+		if _, err := db.ReadUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return certifications, nil
+}
+
+func (db *sqlClient) UpdateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	c := certification
+	res, err := db.db.ExecContext(ctx, db.bind(`UPDATE certifications SET title = ?, institution = ?, state = ?, issued_date = ?, credential_link = ?, description = ? WHERE id = ? AND user_id = ?`),
+		c.Title, c.Institution, c.State, c.IssuedDate, c.CredentialLink, c.Description, c.Id, c.UserID)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.UpdateCertification")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.UpdateCertification")
+	}
+	if n == 0 {
+		return nil, domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", c.Id)
+	}
+	return certification, nil
+}
+
+func (db *sqlClient) DeleteCertification(ctx context.Context, userID, id string) error {
+	res, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM certifications WHERE id = ? AND user_id = ?`), id, userID)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteCertification")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteCertification")
+	}
+	if n == 0 {
+		return domain.NewError(domain.ErrNotFound, "certification with id [ %s ] not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+/*
+Package name : repository
+File name : sql_timeline.go
+Author : Antony Injila
+Description :
+	- Host the database/sql store of experience and education
+	- Like certifications they are written with the user and deleted with it
+	- The achievements of an experience are stored separated by line breaks
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+const (
+	experienceColumns = "id, user_id, company, role, location, start_date, end_date, achievements"
+	educationColumns  = "id, user_id, institution, degree, field_of_study, start_date, end_date, description"
+)
+
+func scanExperience(row rowScanner) (*domain.Experience, error) {
+	var experience domain.Experience
+	var achievements string
+	err := row.Scan(&experience.Id, &experience.UserID, &experience.Company, &experience.Role, &experience.Location,
+		&experience.StartDate, &experience.EndDate, &achievements)
+	if err != nil {
+		return nil, err
+	}
+	if achievements != "" {
+		experience.Achievements = strings.Split(achievements, "\n")
+	}
+	return &experience, nil
+}
+
+func scanEducation(row rowScanner) (*domain.Education, error) {
+	var education domain.Education
+	err := row.Scan(&education.Id, &education.UserID, &education.Institution, &education.Degree, &education.FieldOfStudy,
+		&education.StartDate, &education.EndDate, &education.Description)
+	if err != nil {
+		return nil, err
+	}
+	return &education, nil
+}
+
+// execUserItem runs query, which changes an item of a user, and returns
+// notFound when no row matched.
+func (db *sqlClient) execUserItem(ctx context.Context, method string, notFound error, query string, args ...interface{}) error {
+	res, err := db.db.ExecContext(ctx, db.bind(query), args...)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql."+method)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql."+method)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func (db *sqlClient) CreateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	e := experience
+	// Only insert the experience if its user exists
+	err := db.execUserItem(ctx, "CreateExperience", domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", e.UserID),
+		`INSERT INTO experiences (`+experienceColumns+`) SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM users WHERE id = ?)`,
+		e.Id, e.UserID, e.Company, e.Role, e.Location, e.StartDate, e.EndDate, strings.Join(e.Achievements, "\n"), e.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return experience, nil
+}
+
+func (db *sqlClient) ReadExperience(ctx context.Context, userID, id string) (*domain.Experience, error) {
+	experience, err := scanExperience(db.db.QueryRowContext(ctx, db.bind(`SELECT `+experienceColumns+` FROM experiences WHERE id = ? AND user_id = ?`), id, userID))
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", id)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadExperience")
+	}
+	return experience, nil
+}
+
+func (db *sqlClient) ReadExperiences(ctx context.Context, userID string) ([]*domain.Experience, error) {
+	experiences, err := db.readExperiences(ctx, `WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadExperiences")
+	}
+	if len(experiences) == 0 {
+		// Tell users without experience from missing users
+		if _, err := db.ReadUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	sortExperiences(experiences)
+	return experiences, nil
+}
+
+func (db *sqlClient) UpdateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	e := experience
+	err := db.execUserItem(ctx, "UpdateExperience", domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", e.Id),
+		`UPDATE experiences SET company = ?, role = ?, location = ?, start_date = ?, end_date = ?, achievements = ? WHERE id = ? AND user_id = ?`,
+		e.Company, e.Role, e.Location, e.StartDate, e.EndDate, strings.Join(e.Achievements, "\n"), e.Id, e.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return experience, nil
+}
+
+func (db *sqlClient) DeleteExperience(ctx context.Context, userID, id string) error {
+	return db.execUserItem(ctx, "DeleteExperience", domain.NewError(domain.ErrNotFound, "experience with id [ %s ] not found", id),
+		`DELETE FROM experiences WHERE id = ? AND user_id = ?`, id, userID)
+}
+
+func (db *sqlClient) CreateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	e := education
+	// Only insert the education if its user exists
+	err := db.execUserItem(ctx, "CreateEducation", domain.NewError(domain.ErrNotFound, "user with id [ %s ] not found", e.UserID),
+		`INSERT INTO education (`+educationColumns+`) SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM users WHERE id = ?)`,
+		e.Id, e.UserID, e.Institution, e.Degree, e.FieldOfStudy, e.StartDate, e.EndDate, e.Description, e.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return education, nil
+}
+
+func (db *sqlClient) ReadEducation(ctx context.Context, userID, id string) (*domain.Education, error) {
+	education, err := scanEducation(db.db.QueryRowContext(ctx, db.bind(`SELECT `+educationColumns+` FROM education WHERE id = ? AND user_id = ?`), id, userID))
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", id)
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadEducation")
+	}
+	return education, nil
+}
+
+func (db *sqlClient) ReadEducations(ctx context.Context, userID string) ([]*domain.Education, error) {
+	education, err := db.readEducation(ctx, `WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadEducations")
+	}
+	if len(education) == 0 {
+		// Tell users without education from missing users
+		if _, err := db.ReadUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	sortEducation(education)
+	return education, nil
+}
+
+func (db *sqlClient) UpdateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	e := education
+	err := db.execUserItem(ctx, "UpdateEducation", domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", e.Id),
+		`UPDATE education SET institution = ?, degree = ?, field_of_study = ?, start_date = ?, end_date = ?, description = ? WHERE id = ? AND user_id = ?`,
+		e.Institution, e.Degree, e.FieldOfStudy, e.StartDate, e.EndDate, e.Description, e.Id, e.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return education, nil
+}
+
+func (db *sqlClient) DeleteEducation(ctx context.Context, userID, id string) error {
+	return db.execUserItem(ctx, "DeleteEducation", domain.NewError(domain.ErrNotFound, "education with id [ %s ] not found", id),
+		`DELETE FROM education WHERE id = ? AND user_id = ?`, id, userID)
+}
+
+func (db *sqlClient) readExperiences(ctx context.Context, where string, args ...interface{}) ([]*domain.Experience, error) {
+	rows, err := db.db.QueryContext(ctx, db.bind(`SELECT `+experienceColumns+` FROM experiences `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiences := []*domain.Experience{}
+	for rows.Next() {
+		experience, err := scanExperience(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiences = append(experiences, experience)
+	}
+	return experiences, rows.Err()
+}
+
+func (db *sqlClient) readEducation(ctx context.Context, where string, args ...interface{}) ([]*domain.Education, error) {
+	rows, err := db.db.QueryContext(ctx, db.bind(`SELECT `+educationColumns+` FROM education `+where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	education := []*domain.Education{}
+	for rows.Next() {
+		item, err := scanEducation(rows)
+		if err != nil {
+			return nil, err
+		}
+		education = append(education, item)
+	}
+	return education, rows.Err()
+}
+
+// writeTimeline inserts the experience and education embedded in user.
+func (db *sqlClient) writeTimeline(ctx context.Context, tx *sql.Tx, user *domain.User) error {
+	for _, e := range user.Experiences {
+		_, err := tx.ExecContext(ctx, db.bind(`INSERT INTO experiences (`+experienceColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			e.Id, user.Id, e.Company, e.Role, e.Location, e.StartDate, e.EndDate, strings.Join(e.Achievements, "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range user.Education {
+		_, err := tx.ExecContext(ctx, db.bind(`INSERT INTO education (`+educationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			e.Id, user.Id, e.Institution, e.Degree, e.FieldOfStudy, e.StartDate, e.EndDate, e.Description)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTimeline fills in the experience and education of the users in byID,
+// the ones matched by where.
+func (db *sqlClient) loadTimeline(ctx context.Context, byID map[string]*domain.User, where string, args []interface{}) error {
+	experiences, err := db.readExperiences(ctx, where, args...)
+	if err != nil {
+		return err
+	}
+	sortExperiences(experiences)
+	for _, experience := range experiences {
+		if user, ok := byID[experience.UserID]; ok {
+			user.Experiences = append(user.Experiences, experience)
+		}
+	}
+	education, err := db.readEducation(ctx, where, args...)
+	if err != nil {
+		return err
+	}
+	sortEducation(education)
+	for _, item := range education {
+		if user, ok := byID[item.UserID]; ok {
+			user.Education = append(user.Education, item)
+		}
+	}
+	return nil
+}
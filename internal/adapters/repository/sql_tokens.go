@@ -0,0 +1,133 @@
+/*
+Package name : repository
+File name : sql_tokens.go
+Author : Antony Injila
+Description :
+	- Host the database/sql store of revoked, refresh and password reset tokens
+	- Expired rows are removed whenever a new one is written
+*/
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+func (db *sqlClient) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	// Forget revocations of tokens that have expired since
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM revoked_tokens WHERE expires_at <= ?`), time.Now().Unix())
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.RevokeToken")
+	}
+	_, err = db.db.ExecContext(ctx, db.bind(`INSERT INTO revoked_tokens (id, expires_at) VALUES (?, ?) ON CONFLICT (id) DO NOTHING`),
+		id, expiresAt.Unix())
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.RevokeToken")
+	}
+	return nil
+}
+
+func (db *sqlClient) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	var n int
+	err := db.db.QueryRowContext(ctx, db.bind(`SELECT COUNT(*) FROM revoked_tokens WHERE id = ? AND expires_at > ?`),
+		id, time.Now().Unix()).Scan(&n)
+	if err != nil {
+		return false, errs.Wrap(err, "adapters.repository.sql.IsTokenRevoked")
+	}
+	return n > 0, nil
+}
+
+func (db *sqlClient) CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error {
+	// Forget refresh tokens that have expired
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM refresh_tokens WHERE expires_at <= ?`), time.Now().Unix())
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.CreateRefreshToken")
+	}
+	_, err = db.db.ExecContext(ctx, db.bind(`INSERT INTO refresh_tokens (id, family_id, user_id, expires_at, used) VALUES (?, ?, ?, ?, ?)`),
+		token.Id, token.FamilyID, token.UserID, token.ExpiresAt, token.Used)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.CreateRefreshToken")
+	}
+	return nil
+}
+
+func (db *sqlClient) ReadRefreshToken(ctx context.Context, id string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := db.db.QueryRowContext(ctx, db.bind(`SELECT id, family_id, user_id, expires_at, used FROM refresh_tokens WHERE id = ?`), id).
+		Scan(&token.Id, &token.FamilyID, &token.UserID, &token.ExpiresAt, &token.Used)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "refresh token not found")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ReadRefreshToken")
+	}
+	return &token, nil
+}
+
+func (db *sqlClient) UseRefreshToken(ctx context.Context, id string) error {
+	// Only one of two concurrent requests with the same token updates the row
+	res, err := db.db.ExecContext(ctx, db.bind(`UPDATE refresh_tokens SET used = ? WHERE id = ? AND used = ?`), true, id, false)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.UseRefreshToken")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.UseRefreshToken")
+	}
+	if n == 1 {
+		return nil
+	}
+	if _, err := db.ReadRefreshToken(ctx, id); err != nil {
+		return err
+	}
+	return domain.NewError(domain.ErrConflict, "refresh token was used before")
+}
+
+func (db *sqlClient) DeleteRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM refresh_tokens WHERE family_id = ?`), familyID)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteRefreshTokenFamily")
+	}
+	return nil
+}
+
+func (db *sqlClient) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM refresh_tokens WHERE user_id = ?`), userID)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.DeleteUserRefreshTokens")
+	}
+	return nil
+}
+
+func (db *sqlClient) CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+	// Forget reset tokens that have expired
+	_, err := db.db.ExecContext(ctx, db.bind(`DELETE FROM password_reset_tokens WHERE expires_at <= ?`), time.Now().Unix())
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.CreatePasswordResetToken")
+	}
+	_, err = db.db.ExecContext(ctx, db.bind(`INSERT INTO password_reset_tokens (id, user_id, expires_at) VALUES (?, ?, ?)`),
+		token.Id, token.UserID, token.ExpiresAt)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.CreatePasswordResetToken")
+	}
+	return nil
+}
+
+func (db *sqlClient) ConsumePasswordResetToken(ctx context.Context, id string) (*domain.PasswordResetToken, error) {
+	// Deleting the row returns it to one request only
+	token := domain.PasswordResetToken{Id: id}
+	err := db.db.QueryRowContext(ctx, db.bind(`DELETE FROM password_reset_tokens WHERE id = ? RETURNING user_id, expires_at`), id).
+		Scan(&token.UserID, &token.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.NewError(domain.ErrNotFound, "password reset token not found")
+	}
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sql.ConsumePasswordResetToken")
+	}
+	return &token, nil
+}
@@ -0,0 +1,71 @@
+/*
+Package name : repository
+File name : sql_totp.go
+Author : Antony Injila
+Description :
+	- Host the SQL store of used two-factor codes
+	- Codes are used up with conditional updates, so concurrent logins can't
+	  use one code twice
+*/
+
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	errs "github.com/pkg/errors"
+)
+
+func (db *sqlClient) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	res, err := db.db.ExecContext(ctx, db.bind(`UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`), step, userID, step)
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.UseTOTPStep")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(err, "adapters.repository.sql.UseTOTPStep")
+	}
+	if n == 1 {
+		return nil
+	}
+	if _, err := db.ReadUser(ctx, userID); err != nil {
+		return err
+	}
+	return domain.NewError(domain.ErrConflict, "the code was used before")
+}
+
+func (db *sqlClient) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	// The codes share one column, the update only goes through if nobody
+	// changed it since it was read
+	for attempt := 0; attempt < userListAttempts; attempt++ {
+		user, err := db.ReadUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		codes := []string{}
+		for _, code := range user.RecoveryCodes {
+			if code != hash {
+				codes = append(codes, code)
+			}
+		}
+		if len(codes) == len(user.RecoveryCodes) {
+			return domain.NewError(domain.ErrConflict, "the recovery code was used before")
+		}
+
+		res, err := db.db.ExecContext(ctx, db.bind(`UPDATE users SET recovery_codes = ? WHERE id = ? AND recovery_codes = ?`),
+			strings.Join(codes, ","), userID, strings.Join(user.RecoveryCodes, ","))
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.sql.UseRecoveryCode")
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errs.Wrap(err, "adapters.repository.sql.UseRecoveryCode")
+		}
+		if n == 1 {
+			return nil
+		}
+	}
+	return domain.NewError(domain.ErrConflict, "recovery codes of user [ %s ] changed while they were being used, try again", userID)
+}
@@ -0,0 +1,52 @@
+/*
+Package name : repository
+File name : sqlite.go
+Author : Antony Injila
+Description :
+	- Host the embedded SQLite repository constructor
+	- Keeps users, projects and certifications in a single file
+*/
+
+package repository
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+	errs "github.com/pkg/errors"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+func NewSQLiteRepository(c *config.AppConfig) (ports.PortfolioRepository, error) {
+	dsn := "file:" + c.SQLitePath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errs.Wrap(err, "adapters.repository.sqlite.NewSQLiteRepository")
+	}
+	// SQLite allows a single writer, serialise access instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err = migrate(db, bindQuestion, sqliteMigrations, "migrations/sqlite"); err != nil {
+		db.Close()
+		return nil, errs.Wrap(err, "adapters.repository.sqlite.NewSQLiteRepository")
+	}
+
+	return &sqlClient{
+		db:           db,
+		bind:         bindQuestion,
+		isEmailTaken: isSQLiteEmailTaken,
+	}, nil
+}
+
+func isSQLiteEmailTaken(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), "users.email")
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/AntonyIS/portfolio-be/config"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/repository/repositorytest"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	newRepo := func(t *testing.T) ports.PortfolioRepository {
+		repo, err := NewSQLiteRepository(&config.AppConfig{
+			SQLitePath: filepath.Join(t.TempDir(), "portfolio.db"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}
+	repositorytest.Run(t, newRepo)
+	repositorytest.RunOrderedPages(t, newRepo)
+}
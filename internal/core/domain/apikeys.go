@@ -0,0 +1,33 @@
+/*
+Package name : domain
+File name : apikeys.go
+Author : Antony Injila
+Description :
+	- Host the API keys users create for scripts such as CI pipelines
+	- Only a hash of a key is stored, the key itself is shown once when it is created
+*/
+package domain
+
+// APIKey lets a script act as the user, limited to the scopes of the key.
+// The key handed out is the Id, which is safe to show, a dot and a secret.
+type APIKey struct {
+	Id        string   `json:"id"`
+	UserID    string   `json:"user_id"`
+	Name      string   `json:"name"`
+	Hash      string   `json:"-"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"created_at"`
+	// ExpiresAt is 0 for keys that don't expire
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// APIKeyScopes are the permissions of the authorization policy an API key
+// can be given. Keys can't manage API keys.
+var APIKeyScopes = []string{
+	"users:list",
+	"users:update",
+	"users:delete",
+	"users:roles",
+	"projects:write",
+	"projects:delete",
+}
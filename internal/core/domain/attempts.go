@@ -0,0 +1,17 @@
+/*
+Package name : domain
+File name : attempts.go
+Author : Antony Injila
+Description :
+	- Host the count of failed logins of an account or of a client address
+*/
+package domain
+
+// LoginAttempts counts the failed logins for a key since the last successful
+// one. The count is forgotten at ExpiresAt.
+type LoginAttempts struct {
+	Key         string `json:"id"`
+	Failures    int    `json:"failures"`
+	LastFailure int64  `json:"last_failure"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
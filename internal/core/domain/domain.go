@@ -4,6 +4,7 @@ File name : domain.go
 Author : Antony Injila
 Description :
 	- Host Portfolio entiry strunctures such as a User and a Project
+	- The experience and education on the timeline of a user are in timeline.go
 	- User types have the GenerateHashPassord and CheckPasswordHarsh methods
 */
 package domain
@@ -18,11 +19,29 @@ type User struct {
 	LastName       string           `json:"lastname"`
 	Email          string           `json:"email"`
 	Title          string           `json:"title"`
-	Password       string           `json:"password"`
+	Password       string           `json:"-" dynamodbav:"password"`
+	Role           Role             `json:"role"`
+	EmailVerified  bool             `json:"email_verified"`
+	TOTPEnabled    bool             `json:"totp_enabled"`
 	Projects       []*Project       `json:"projects"`
-	Certifications []*Certification `json:"certification"`
+	Certifications []*Certification `json:"certifications" dynamodbav:"certifications"`
+	Experiences    []*Experience    `json:"experiences" dynamodbav:"experiences"`
+	Education      []*Education     `json:"education" dynamodbav:"education"`
+
+	// The TOTP secret, the last time step a code was used for and the
+	// hashed recovery codes are stored but never sent to clients, like the
+	// password hash
+	TOTPSecret    string   `json:"-" dynamodbav:"totp_secret"`
+	TOTPLastStep  int64    `json:"-" dynamodbav:"totp_last_step"`
+	RecoveryCodes []string `json:"-" dynamodbav:"recovery_codes"`
+	// Identities are the accounts at identity providers linked to the user,
+	// as provider:subject
+	Identities []string `json:"-" dynamodbav:"identities"`
 }
 
+// Certification is a certificate on the profile of a user. IssuedDate is an
+// ISO 8601 date such as 2023-05 or 2023-05-14, certifications are listed
+// newest first.
 type Certification struct {
 	Id             string `json:"id"`
 	UserID         string `json:"user_id"`
@@ -31,7 +50,7 @@ type Certification struct {
 	State          string `json:"state"`
 	IssuedDate     string `json:"issued_date"`
 	CredentialLink string `json:"credential_link"`
-	Decription     string `json:"decription"`
+	Description    string `json:"description"`
 }
 type Project struct {
 	Id        string `json:"id"`
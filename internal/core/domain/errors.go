@@ -0,0 +1,52 @@
+/*
+Package name : domain
+File name : errors.go
+Author : Antony Injila
+Description :
+	- Host the kinds of errors the portfolio services and repositories return
+	- Adapters check the kind with errors.Is, e.g. to pick an HTTP status code
+*/
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	// ErrTooManyRequests errors tell the client when to try again with RetryAfter
+	ErrTooManyRequests = errors.New("too many requests")
+
+	ErrEmailTaken = NewError(ErrConflict, "user with email exists")
+)
+
+// Error is an error of one of the kinds above. Its message is meant to be
+// shown to clients.
+type Error struct {
+	Kind    error
+	Message string
+	// RetryAfter is how long the client should wait before trying again
+	RetryAfter time.Duration
+}
+
+// NewError returns an error of the given kind with a formatted message.
+func NewError(kind error, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
@@ -0,0 +1,24 @@
+/*
+Package name : domain
+File name : identity.go
+Author : Antony Injila
+Description :
+	- Host the identity of a user at an identity provider such as GitHub or Google
+*/
+package domain
+
+// ExternalIdentity is who an identity provider says logged in. Subject is
+// the id of the account at the provider, it doesn't change with the email.
+type ExternalIdentity struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	FirstName     string `json:"firstname"`
+	LastName      string `json:"lastname"`
+}
+
+// Key is how the identity is linked to a user.
+func (i ExternalIdentity) Key() string {
+	return i.Provider + ":" + i.Subject
+}
@@ -0,0 +1,14 @@
+/*
+Package name : domain
+File name : mail.go
+Author : Antony Injila
+Description :
+	- Host the emails the portfolio services send to users
+*/
+package domain
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
@@ -0,0 +1,29 @@
+/*
+Package name : domain
+File name : roles.go
+Author : Antony Injila
+Description :
+	- Host the roles a user can have
+	- What each role may do is decided by the policy in the middleware adapter
+*/
+package domain
+
+type Role string
+
+const (
+	// RoleAdmin moderates the site and may remove any account or project
+	RoleAdmin Role = "admin"
+	// RoleOwner publishes their own portfolio, every new user is an owner
+	RoleOwner Role = "owner"
+	// RoleViewer may only manage their own account
+	RoleViewer Role = "viewer"
+)
+
+// Valid reports whether r is one of the roles above.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleOwner, RoleViewer:
+		return true
+	}
+	return false
+}
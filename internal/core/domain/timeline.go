@@ -0,0 +1,34 @@
+/*
+Package name : domain
+File name : timeline.go
+Author : Antony Injila
+Description :
+	- Host the CV timeline of a user, their work experience and education
+	- Dates are ISO 8601 such as 2021-09 or 2021-09-01, entries without an end
+	  date are ongoing
+*/
+package domain
+
+// Experience is a job on the timeline of a user.
+type Experience struct {
+	Id           string   `json:"id"`
+	UserID       string   `json:"user_id"`
+	Company      string   `json:"company"`
+	Role         string   `json:"role"`
+	Location     string   `json:"location"`
+	StartDate    string   `json:"start_date"`
+	EndDate      string   `json:"end_date"`
+	Achievements []string `json:"achievements"`
+}
+
+// Education is a school or course on the timeline of a user.
+type Education struct {
+	Id           string `json:"id"`
+	UserID       string `json:"user_id"`
+	Institution  string `json:"institution"`
+	Degree       string `json:"degree"`
+	FieldOfStudy string `json:"field_of_study"`
+	StartDate    string `json:"start_date"`
+	EndDate      string `json:"end_date"`
+	Description  string `json:"description"`
+}
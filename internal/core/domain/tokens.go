@@ -0,0 +1,27 @@
+/*
+Package name : domain
+File name : tokens.go
+Author : Antony Injila
+Description :
+	- Host the refresh tokens handed out at login and the password reset tokens
+	- Only a hash of a token is stored, the token itself stays with the client
+*/
+package domain
+
+// RefreshToken is exchanged for a new access token and a new refresh token.
+// Every token rotated from the same login belongs to the same family.
+type RefreshToken struct {
+	Id        string `json:"id"`
+	FamilyID  string `json:"family_id"`
+	UserID    string `json:"user_id"`
+	ExpiresAt int64  `json:"expires_at"`
+	Used      bool   `json:"used"`
+}
+
+// PasswordResetToken lets the owner of an email address set a new password.
+// It can be used once.
+type PasswordResetToken struct {
+	Id        string `json:"id"`
+	UserID    string `json:"user_id"`
+	ExpiresAt int64  `json:"expires_at"`
+}
@@ -4,36 +4,158 @@ File name : ports.go
 Author : Antony Injila
 Description :
 	- Host code the describe the purpose of the application
-	- Has the Portifolio service and repository interfaces
+	- Has the Portifolio service, repository and mailer interfaces
+	- Listings take a page size and the cursor returned with the previous page,
+	  a limit of 0 returns everything and an empty next cursor marks the last page
+	- Every page is ordered, only some adapters keep the order across pages
+	- Revoked tokens are kept until they would have expired anyway
+	- Refresh tokens are looked up by the hash of the token, UseRefreshToken
+	  fails with ErrConflict when the token was used before
+	- ConsumePasswordResetToken deletes the token it returns, so it can be used once
+	- Login attempt counts that have expired are reported as not found
+	- Users listed by ReadUsers come without password and two-factor secrets
+	- Certifications are read and changed through the user they belong to and
+	  listed newest first
+	- Experience and education are read and changed the same way and listed
+	  most recent first, ongoing entries before the ones that ended
+	- API keys are looked up by their id, ReadAPIKeys lists the keys of a user
+	  oldest first
+	- Identity providers log users in with the OAuth2 authorization code flow
+	  and PKCE, the client keeps the state and the code verifier
 */
 package ports
 
-import "github.com/AntonyIS/portfolio-be/internal/core/domain"
+import (
+	"context"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
 
 type PortfolioService interface {
-	CreateUser(user *domain.User) (*domain.User, error)
-	ReadUser(id string) (*domain.User, error)
-	ReadUserWithEmail(email string) (*domain.User, error)
-	ReadUsers() ([]*domain.User, error)
-	UpdateUser(user *domain.User) (*domain.User, error)
-	DeleteUser(id string) error
-	CreateProject(Project *domain.Project) (*domain.Project, error)
-	ReadProject(id string) (*domain.Project, error)
-	ReadProjects() ([]*domain.Project, error)
-	UpdateProject(Project *domain.Project) (*domain.Project, error)
-	DeleteProject(id string) error
+	CreateUser(ctx context.Context, user *domain.User) (*domain.User, error)
+	Authenticate(ctx context.Context, email, password, address string) (*domain.User, error)
+	ReadUser(ctx context.Context, id string) (*domain.User, error)
+	ReadUserWithEmail(ctx context.Context, email string) (*domain.User, error)
+	ReadUsers(ctx context.Context, limit int, cursor string) ([]*domain.User, string, error)
+	UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error)
+	SetUserRole(ctx context.Context, id string, role domain.Role) (*domain.User, error)
+	DeleteUser(ctx context.Context, id string) error
+	CreateProject(ctx context.Context, Project *domain.Project) (*domain.Project, error)
+	ReadProject(ctx context.Context, id string) (*domain.Project, error)
+	ReadProjects(ctx context.Context, limit int, cursor string) ([]*domain.Project, string, error)
+	UpdateProject(ctx context.Context, Project *domain.Project) (*domain.Project, error)
+	DeleteProject(ctx context.Context, id string) error
+	CreateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error)
+	ReadCertification(ctx context.Context, userID, id string) (*domain.Certification, error)
+	ReadCertifications(ctx context.Context, userID string) ([]*domain.Certification, error)
+	UpdateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error)
+	DeleteCertification(ctx context.Context, userID, id string) error
+	CreateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error)
+	ReadExperience(ctx context.Context, userID, id string) (*domain.Experience, error)
+	ReadExperiences(ctx context.Context, userID string) ([]*domain.Experience, error)
+	UpdateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error)
+	DeleteExperience(ctx context.Context, userID, id string) error
+	CreateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error)
+	ReadEducation(ctx context.Context, userID, id string) (*domain.Education, error)
+	ReadEducations(ctx context.Context, userID string) ([]*domain.Education, error)
+	UpdateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error)
+	DeleteEducation(ctx context.Context, userID, id string) error
+	RevokeToken(ctx context.Context, id string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, id string) (bool, error)
+	IssueRefreshToken(ctx context.Context, userID string) (string, error)
+	RotateRefreshToken(ctx context.Context, token string) (string, string, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	SendVerificationEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) (*domain.User, error)
+	EnrollTOTP(ctx context.Context, userID string) (string, string, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID, code string) error
+	LoginChallenge(user *domain.User) string
+	CompleteLogin(ctx context.Context, challenge, code, address string) (*domain.User, error)
+	IdentityProviderURL(provider, state, verifier string) (string, error)
+	LoginWithIdentityProvider(ctx context.Context, provider, code, verifier string) (*domain.User, error)
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (string, *domain.APIKey, error)
+	ReadAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*domain.User, *domain.APIKey, error)
 }
 
 type PortfolioRepository interface {
-	CreateUser(user *domain.User) (*domain.User, error)
-	ReadUser(id string) (*domain.User, error)
-	ReadUserWithEmail(email string) (*domain.User, error)
-	ReadUsers() ([]*domain.User, error)
-	UpdateUser(user *domain.User) (*domain.User, error)
-	DeleteUser(id string) error
-	CreateProject(Project *domain.Project) (*domain.Project, error)
-	ReadProject(id string) (*domain.Project, error)
-	ReadProjects() ([]*domain.Project, error)
-	UpdateProject(Project *domain.Project) (*domain.Project, error)
-	DeleteProject(id string) error
+	CreateUser(ctx context.Context, user *domain.User) (*domain.User, error)
+	ReadUser(ctx context.Context, id string) (*domain.User, error)
+	ReadUserWithEmail(ctx context.Context, email string) (*domain.User, error)
+	// ReadUserWithIdentity returns the user an identity is linked to, by
+	// the key of the identity.
+	ReadUserWithIdentity(ctx context.Context, key string) (*domain.User, error)
+	ReadUsers(ctx context.Context, limit int, cursor string) ([]*domain.User, string, error)
+	UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error)
+	DeleteUser(ctx context.Context, id string) error
+	// UseTOTPStep records that a TOTP code of step was used by the user with
+	// userID. It fails with ErrConflict unless step is after the last one
+	// recorded, so of two logins with the same code only one gets through.
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	// UseRecoveryCode removes hash from the recovery codes of the user with
+	// userID. It fails with ErrConflict if the code is not there anymore.
+	UseRecoveryCode(ctx context.Context, userID, hash string) error
+	CreateProject(ctx context.Context, Project *domain.Project) (*domain.Project, error)
+	ReadProject(ctx context.Context, id string) (*domain.Project, error)
+	ReadProjects(ctx context.Context, limit int, cursor string) ([]*domain.Project, string, error)
+	UpdateProject(ctx context.Context, Project *domain.Project) (*domain.Project, error)
+	DeleteProject(ctx context.Context, id string) error
+	CreateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error)
+	ReadCertification(ctx context.Context, userID, id string) (*domain.Certification, error)
+	ReadCertifications(ctx context.Context, userID string) ([]*domain.Certification, error)
+	UpdateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error)
+	DeleteCertification(ctx context.Context, userID, id string) error
+	CreateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error)
+	ReadExperience(ctx context.Context, userID, id string) (*domain.Experience, error)
+	ReadExperiences(ctx context.Context, userID string) ([]*domain.Experience, error)
+	UpdateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error)
+	DeleteExperience(ctx context.Context, userID, id string) error
+	CreateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error)
+	ReadEducation(ctx context.Context, userID, id string) (*domain.Education, error)
+	ReadEducations(ctx context.Context, userID string) ([]*domain.Education, error)
+	UpdateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error)
+	DeleteEducation(ctx context.Context, userID, id string) error
+	RevokeToken(ctx context.Context, id string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, id string) (bool, error)
+	CreateRefreshToken(ctx context.Context, token *domain.RefreshToken) error
+	ReadRefreshToken(ctx context.Context, id string) (*domain.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, id string) error
+	DeleteRefreshTokenFamily(ctx context.Context, familyID string) error
+	DeleteUserRefreshTokens(ctx context.Context, userID string) error
+	CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error
+	ConsumePasswordResetToken(ctx context.Context, id string) (*domain.PasswordResetToken, error)
+	CreateAPIKey(ctx context.Context, key *domain.APIKey) error
+	ReadAPIKey(ctx context.Context, id string) (*domain.APIKey, error)
+	ReadAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	LoginAttemptStore
+}
+
+// LoginAttemptStore counts failed logins. Every repository is one, an
+// in-memory store can be used instead to keep the counts off the database.
+type LoginAttemptStore interface {
+	// RecordLoginFailure adds a failure at now to the count of key and
+	// returns it. A count past its expiry starts over, the new count expires
+	// at expiresAt.
+	RecordLoginFailure(ctx context.Context, key string, now, expiresAt time.Time) (*domain.LoginAttempts, error)
+	ReadLoginAttempts(ctx context.Context, key string) (*domain.LoginAttempts, error)
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+// IdentityProvider is an OAuth2 or OpenID Connect provider users log in with.
+type IdentityProvider interface {
+	// AuthCodeURL is where the user logs in at the provider, which redirects
+	// back with a code and state. The code challenge is derived from verifier.
+	AuthCodeURL(state, verifier string) string
+	// Exchange trades the code for the identity of the user who logged in
+	Exchange(ctx context.Context, code, verifier string) (*domain.ExternalIdentity, error)
+}
+
+type Mailer interface {
+	Send(ctx context.Context, mail *domain.Mail) error
 }
@@ -0,0 +1,150 @@
+/*
+Package name : services
+File name : apikeys.go
+Author : Antony Injila
+Description :
+	- Host the logic for the API keys users create for scripts such as CI pipelines
+	- A key is its id, which starts with pfk_ so it can be recognised, a dot
+	  and a secret. Only the hash of the key is stored
+	- Keys of deleted users stop working as their user can't be read
+*/
+
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to spot.
+const APIKeyPrefix = "pfk_"
+
+// maxAPIKeys is how many keys a user can have at once.
+const maxAPIKeys = 20
+
+var errInvalidAPIKey = domain.NewError(domain.ErrUnauthorized, "Invalid API key")
+
+// CreateAPIKey returns a new key of the user limited to scopes, and what is
+// stored of it. The key is never returned again. Keys with a zero expiresAt
+// don't expire.
+func (svc *PortfolioService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (string, *domain.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, domain.NewError(domain.ErrValidation, "name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, domain.NewError(domain.ErrValidation, "at least one scope is required")
+	}
+	seen := map[string]bool{}
+	var keyScopes []string
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return "", nil, domain.NewError(domain.ErrValidation, "unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			keyScopes = append(keyScopes, scope)
+			seen[scope] = true
+		}
+	}
+	now := time.Now()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return "", nil, domain.NewError(domain.ErrValidation, "expiry must be in the future")
+	}
+	if _, err := svc.repo.ReadUser(ctx, userID); err != nil {
+		return "", nil, err
+	}
+	existing, err := svc.repo.ReadAPIKeys(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(existing) >= maxAPIKeys {
+		return "", nil, domain.NewError(domain.ErrConflict, "you have %d API keys, revoke one first", len(existing))
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	key := &domain.APIKey{
+		Id:        APIKeyPrefix + hex.EncodeToString(b),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    keyScopes,
+		CreatedAt: now.Unix(),
+	}
+	if !expiresAt.IsZero() {
+		key.ExpiresAt = expiresAt.Unix()
+	}
+	token := key.Id + "." + secret
+	key.Hash = hashToken(token)
+	if err := svc.repo.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return token, key, nil
+}
+
+func (svc *PortfolioService) ReadAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	return svc.repo.ReadAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey deletes the key id of the user.
+func (svc *PortfolioService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	key, err := svc.repo.ReadAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Keys of other users are as good as missing
+	if key.UserID != userID {
+		return domain.NewError(domain.ErrNotFound, "API key [ %s ] not found", id)
+	}
+	return svc.repo.DeleteAPIKey(ctx, id)
+}
+
+// AuthenticateAPIKey returns the user of key and what is stored of the key.
+func (svc *PortfolioService) AuthenticateAPIKey(ctx context.Context, key string) (*domain.User, *domain.APIKey, error) {
+	id, _, ok := strings.Cut(key, ".")
+	if !ok || !strings.HasPrefix(id, APIKeyPrefix) {
+		return nil, nil, errInvalidAPIKey
+	}
+	stored, err := svc.repo.ReadAPIKey(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(stored.Hash)) != 1 {
+		return nil, nil, errInvalidAPIKey
+	}
+	if stored.ExpiresAt != 0 && stored.ExpiresAt <= time.Now().Unix() {
+		return nil, nil, domain.NewError(domain.ErrUnauthorized, "API key has expired")
+	}
+	user, err := svc.repo.ReadUser(ctx, stored.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, stored, nil
+}
+
+func validScope(scope string) bool {
+	for _, valid := range domain.APIKeyScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
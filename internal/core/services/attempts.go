@@ -0,0 +1,128 @@
+/*
+Package name : services
+File name : attempts.go
+Author : Antony Injila
+Description :
+	- Host the protection of logins against guessing passwords and codes
+	- Failed logins are counted per account and per client address. After a few
+	  failures the next login has to wait, twice as long after every further
+	  failure, until enough failures lock the account or address for a while
+*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+const (
+	// LoginBackoff is the wait after the free failures, it doubles with every
+	// further failure
+	LoginBackoff = time.Second
+	// LoginLockout is how long an account or address is locked once it has
+	// failed too often, and the longest a login ever has to wait
+	LoginLockout = 15 * time.Minute
+	// LoginFailureWindow is how long failures are remembered after the last one
+	LoginFailureWindow = 24 * time.Hour
+)
+
+// loginLimit is how many failures of one kind of key are allowed.
+type loginLimit struct {
+	prefix string
+	// free failures don't make the next login wait
+	free int
+	// lockout failures lock the key for LoginLockout
+	lockout int
+}
+
+var (
+	accountLoginLimit = loginLimit{prefix: "account#", free: 3, lockout: 10}
+	// Many users can share an address, so it is allowed more failures
+	addressLoginLimit = loginLimit{prefix: "address#", free: 10, lockout: 50}
+)
+
+// wait returns how long after the last of failures the next login may start.
+func (l loginLimit) wait(failures int) time.Duration {
+	if failures < l.free {
+		return 0
+	}
+	shift := failures - l.free
+	if failures >= l.lockout || shift > 30 {
+		return LoginLockout
+	}
+	if wait := LoginBackoff << shift; wait < LoginLockout {
+		return wait
+	}
+	return LoginLockout
+}
+
+type loginKey struct {
+	limit loginLimit
+	key   string
+}
+
+// loginKeys returns the keys failed logins to the account with email from
+// address are counted under. The account key comes first.
+func loginKeys(email, address string) []loginKey {
+	keys := []loginKey{{limit: accountLoginLimit, key: accountLoginLimit.prefix + strings.ToLower(email)}}
+	if address != "" {
+		keys = append(keys, loginKey{limit: addressLoginLimit, key: addressLoginLimit.prefix + address})
+	}
+	return keys
+}
+
+// checkLoginAttempts fails with ErrTooManyRequests while one of keys has to
+// wait after its last failure.
+func (svc *PortfolioService) checkLoginAttempts(ctx context.Context, keys []loginKey) error {
+	var retryAfter time.Duration
+	for _, k := range keys {
+		attempts, err := svc.attempts.ReadLoginAttempts(ctx, k.key)
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		wait := k.limit.wait(attempts.Failures)
+		if wait == 0 {
+			continue
+		}
+		// Failures are stored in whole seconds, counting them at the end of
+		// their second never cuts a wait short
+		wait = time.Until(time.Unix(attempts.LastFailure+1, 0).Add(wait))
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter <= 0 {
+		return nil
+	}
+	// Clients are told to wait whole seconds
+	retryAfter = (retryAfter + time.Second - 1).Truncate(time.Second)
+	err := domain.NewError(domain.ErrTooManyRequests, "too many failed logins, try again in %s", retryAfter)
+	err.RetryAfter = retryAfter
+	return err
+}
+
+// loginFailed counts a failed login under keys and returns the error for it.
+func (svc *PortfolioService) loginFailed(ctx context.Context, keys []loginKey, loginErr error) error {
+	now := time.Now()
+	for _, k := range keys {
+		if _, err := svc.attempts.RecordLoginFailure(ctx, k.key, now, now.Add(LoginFailureWindow)); err != nil {
+			return err
+		}
+	}
+	return loginErr
+}
+
+// loginSucceeded forgets the failures of the account. Failures of the
+// address are kept, or logging in to one account would allow guessing more
+// passwords of others.
+func (svc *PortfolioService) loginSucceeded(ctx context.Context, keys []loginKey) error {
+	return svc.attempts.ResetLoginAttempts(ctx, keys[0].key)
+}
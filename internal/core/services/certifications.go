@@ -0,0 +1,71 @@
+/*
+Package name : services
+File name : certifications.go
+Author : Antony Injila
+Description :
+	- Host the logic for the certifications on the profile of a user
+	- Certifications are always read and changed through their user, the
+	  certification of another user is as good as missing
+*/
+
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+func (svc *PortfolioService) CreateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	if err := validateCertification(certification); err != nil {
+		return nil, err
+	}
+	certification.Id = uuid.New().String()
+	return svc.repo.CreateCertification(ctx, certification)
+}
+
+func (svc *PortfolioService) ReadCertification(ctx context.Context, userID, id string) (*domain.Certification, error) {
+	return svc.repo.ReadCertification(ctx, userID, id)
+}
+
+func (svc *PortfolioService) ReadCertifications(ctx context.Context, userID string) ([]*domain.Certification, error) {
+	return svc.repo.ReadCertifications(ctx, userID)
+}
+
+func (svc *PortfolioService) UpdateCertification(ctx context.Context, certification *domain.Certification) (*domain.Certification, error) {
+	if err := validateCertification(certification); err != nil {
+		return nil, err
+	}
+	return svc.repo.UpdateCertification(ctx, certification)
+}
+
+func (svc *PortfolioService) DeleteCertification(ctx context.Context, userID, id string) error {
+	return svc.repo.DeleteCertification(ctx, userID, id)
+}
+
+// validateCertification checks what the user entered. Issued dates are
+// listed newest first, which only works for ISO 8601 dates.
+func validateCertification(certification *domain.Certification) error {
+	certification.Title = strings.TrimSpace(certification.Title)
+	if certification.Title == "" {
+		return domain.NewError(domain.ErrValidation, "title is required")
+	}
+	if date := certification.IssuedDate; date != "" && !isISODate(date) {
+		return domain.NewError(domain.ErrValidation, "issued_date %q is not a date such as 2023-05 or 2023-05-14", date)
+	}
+	return nil
+}
+
+// isISODate reports whether date is a year and month, or a full date, in
+// ISO 8601 form.
+func isISODate(date string) bool {
+	for _, layout := range []string{"2006-01", "2006-01-02"} {
+		if _, err := time.Parse(layout, date); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+/*
+Package name : services
+File name : identity.go
+Author : Antony Injila
+Description :
+	- Host the logic for logging in with an identity provider such as GitHub or Google
+	- Identities log in to the user they are linked to. New identities are
+	  linked to the user with the same verified email, users without an
+	  account get one
+*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// IdentityProviderURL returns where the user logs in with provider. The
+// client keeps state and verifier for LoginWithIdentityProvider.
+func (svc *PortfolioService) IdentityProviderURL(provider, state, verifier string) (string, error) {
+	idp, ok := svc.options.IdentityProviders[provider]
+	if !ok {
+		return "", domain.NewError(domain.ErrNotFound, "identity provider %q not found", provider)
+	}
+	return idp.AuthCodeURL(state, verifier), nil
+}
+
+// LoginWithIdentityProvider returns the user who logged in at provider and
+// was redirected back with code. An identity linked before logs in to its
+// user, even if its email changed since. Otherwise it is linked to the user
+// with the email the provider verified, or to a new user. Each user links one
+// account per provider, so a provider account that took over an email
+// doesn't get into the portfolio account.
+func (svc *PortfolioService) LoginWithIdentityProvider(ctx context.Context, provider, code, verifier string) (*domain.User, error) {
+	idp, ok := svc.options.IdentityProviders[provider]
+	if !ok {
+		return nil, domain.NewError(domain.ErrNotFound, "identity provider %q not found", provider)
+	}
+	if code == "" {
+		return nil, domain.NewError(domain.ErrValidation, "code is required")
+	}
+	identity, err := idp.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	identity.Provider = provider
+	identity.Email = normalizeEmail(identity.Email)
+
+	user, err := svc.repo.ReadUserWithIdentity(ctx, identity.Key())
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+
+	// The identity is new, the email decides whose it is
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, domain.NewError(domain.ErrForbidden, "your %s account has no verified email", provider)
+	}
+	user, err = svc.repo.ReadUserWithEmail(ctx, identity.Email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return svc.createUserWithIdentity(ctx, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, linked := range user.Identities {
+		if strings.HasPrefix(linked, provider+":") {
+			return nil, domain.NewError(domain.ErrForbidden, "this account is linked to another %s account", provider)
+		}
+	}
+	// Whoever signed up with an email they don't own mustn't share the
+	// account with its owner
+	if !user.EmailVerified {
+		return nil, domain.NewError(domain.ErrForbidden, "verify your email address before logging in with %s", provider)
+	}
+	user.Identities = append(user.Identities, identity.Key())
+	return svc.repo.UpdateUser(ctx, user)
+}
+
+// createUserWithIdentity signs up the user of identity. The password is
+// random, users who want one set it with ForgotPassword.
+func (svc *PortfolioService) createUserWithIdentity(ctx context.Context, identity *domain.ExternalIdentity) (*domain.User, error) {
+	password, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	return svc.repo.CreateUser(ctx, &domain.User{
+		Id:            uuid.New().String(),
+		FirstName:     identity.FirstName,
+		LastName:      identity.LastName,
+		Email:         identity.Email,
+		Password:      hashedPassword,
+		Role:          domain.RoleOwner,
+		EmailVerified: true,
+		Identities:    []string{identity.Key()},
+	})
+}
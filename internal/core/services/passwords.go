@@ -0,0 +1,97 @@
+/*
+Package name : services
+File name : passwords.go
+Author : Antony Injila
+Description :
+	- Host the logic for users that forgot their password
+	- A link with a single use reset token is mailed to the address of the account
+*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+// PasswordResetTTL is how long the link in a password reset email works.
+const PasswordResetTTL = time.Hour
+
+var errInvalidResetToken = domain.NewError(domain.ErrValidation, "password reset token is invalid or expired")
+
+// ForgotPassword mails a password reset link to the user with email. It
+// succeeds for unknown emails as well, so it can't be used to find accounts.
+func (svc *PortfolioService) ForgotPassword(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	if email == "" {
+		return domain.NewError(domain.ErrValidation, "email is required")
+	}
+	user, err := svc.repo.ReadUserWithEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	err = svc.repo.CreatePasswordResetToken(ctx, &domain.PasswordResetToken{
+		Id:        hashToken(token),
+		UserID:    user.Id,
+		ExpiresAt: time.Now().Add(PasswordResetTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", svc.appURL, url.QueryEscape(token))
+	return svc.mailer.Send(ctx, &domain.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below within %s to choose a new password:\n\n%s\n\nIf you did not ask for a new password, ignore this email.\n",
+			user.FirstName, PasswordResetTTL, link),
+	})
+}
+
+// ResetPassword sets the password of the user the reset token was mailed to.
+func (svc *PortfolioService) ResetPassword(ctx context.Context, token, password string) error {
+	if password == "" {
+		return domain.NewError(domain.ErrValidation, "password is required")
+	}
+	stored, err := svc.repo.ConsumePasswordResetToken(ctx, hashToken(token))
+	if errors.Is(err, domain.ErrNotFound) {
+		return errInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if stored.ExpiresAt <= time.Now().Unix() {
+		return errInvalidResetToken
+	}
+
+	user, err := svc.repo.ReadUser(ctx, stored.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return errInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if user.Password, err = hashPassword(password); err != nil {
+		return err
+	}
+	// The reset link was opened from the inbox of the user
+	user.EmailVerified = true
+	if _, err = svc.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	// Log out every session, someone else may hold one of them
+	return svc.repo.DeleteUserRefreshTokens(ctx, user.Id)
+}
@@ -1,24 +1,30 @@
 package services
 
 import (
+	"context"
+	"encoding/base32"
+	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/AntonyIS/portfolio-be/config"
 	"github.com/AntonyIS/portfolio-be/internal/adapters/repository"
 	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
 )
 
-func init() {
-	config.LoadEnv("../../../.env")
-}
-
 func TestApplicationService(t *testing.T) {
 
-	config := config.NewConfiguration("dev")
-	repo := repository.NewDynamoDBRepository(config)
-	svc := NewPortfolioService(&repo)
+	ctx := context.Background()
+	repo := repository.NewInMemoryRepository()
+	mailbox := &testMailer{}
+	options := Options{AppURL: "http://localhost:3000", SecretKey: []byte("test-secret")}
+	svc := NewPortfolioService(&repo, mailbox, options)
 
 	t.Run("Test create new user", func(t *testing.T) {
 		newUser := domain.User{
@@ -29,10 +35,10 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		_, err := svc.ReadUserWithEmail(newUser.Email)
+		_, err := svc.ReadUserWithEmail(ctx, newUser.Email)
 
 		if err != nil {
-			user, err := svc.CreateUser(&newUser)
+			user, err := svc.CreateUser(ctx, &newUser)
 
 			if err != nil {
 				t.Error(err)
@@ -42,13 +48,45 @@ func TestApplicationService(t *testing.T) {
 			}
 
 			// Delete user
-			err = svc.DeleteUser(user.Id)
+			err = svc.DeleteUser(ctx, user.Id)
 			if err != nil {
 				t.Error(err)
 			}
 		}
 
 	})
+	t.Run("Create users with the same email concurrently", func(t *testing.T) {
+		var wg sync.WaitGroup
+		created := make(chan *domain.User, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				user, err := svc.CreateUser(ctx, &domain.User{
+					FirstName: "Antony",
+					LastName:  "Injila",
+					Email:     "concurrent@gmail.com",
+					Password:  "password",
+				})
+				if err == nil {
+					created <- user
+				} else if !errors.Is(err, domain.ErrEmailTaken) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		close(created)
+
+		count := 0
+		for user := range created {
+			count++
+			svc.DeleteUser(ctx, user.Id)
+		}
+		if count != 1 {
+			t.Errorf("%d users were created with the same email, want 1", count)
+		}
+	})
 	t.Run("Read user with email", func(t *testing.T) {
 		newUser := domain.User{
 			FirstName: "Antony",
@@ -59,11 +97,11 @@ func TestApplicationService(t *testing.T) {
 			Projects:  nil,
 		}
 
-		user, err := svc.CreateUser(&newUser)
+		user, err := svc.CreateUser(ctx, &newUser)
 		if err != nil {
 			t.Error(err)
 		}
-		user, err = svc.ReadUserWithEmail(user.Email)
+		user, err = svc.ReadUserWithEmail(ctx, user.Email)
 		if err != nil {
 			t.Error(err)
 		}
@@ -71,14 +109,40 @@ func TestApplicationService(t *testing.T) {
 			t.Errorf("User with email %s is not same as %s ", user.Email, newUser.Email)
 		}
 		// Delete user
-		err = svc.DeleteUser(user.Id)
+		err = svc.DeleteUser(ctx, user.Id)
 		if err != nil {
 			t.Error(err)
 		}
 
 	})
+	t.Run("Emails differing in case are one account", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{
+			FirstName: "Antony",
+			LastName:  "Injila",
+			Email:     " Case@Gmail.com ",
+			Password:  "password",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+		if user.Email != "case@gmail.com" {
+			t.Errorf("stored email %q, want %q", user.Email, "case@gmail.com")
+		}
+
+		_, err = svc.CreateUser(ctx, &domain.User{Email: "CASE@gmail.com", Password: "password"})
+		if !errors.Is(err, domain.ErrEmailTaken) {
+			t.Errorf("signing up with the email in upper case returned %v, want %v", err, domain.ErrEmailTaken)
+		}
+		if _, err := svc.ReadUserWithEmail(ctx, "CASE@GMAIL.COM"); err != nil {
+			t.Errorf("reading the user with the email in upper case: %v", err)
+		}
+		if _, err := svc.Authenticate(ctx, "Case@gmail.com", "password", ""); err != nil {
+			t.Errorf("logging in with the email in mixed case: %v", err)
+		}
+	})
 	t.Run("Read users", func(t *testing.T) {
-		users, err := svc.ReadUsers()
+		users, _, err := svc.ReadUsers(ctx, 0, "")
 		if err != nil {
 			t.Error(err)
 		}
@@ -97,26 +161,631 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		DBuser, err := svc.CreateUser(&newUser)
+		DBuser, err := svc.CreateUser(ctx, &newUser)
 		if err != nil {
 			t.Error(err)
 		}
 		DBuser.FirstName = "John"
 		DBuser.LastName = "john@gmail.com"
+		// Profile updates come without the password
+		DBuser.Password = ""
 
-		user, err := svc.UpdateUser(DBuser)
+		user, err := svc.UpdateUser(ctx, DBuser)
 		if err != nil {
 			t.Error(err)
 		}
 		if user.FirstName != DBuser.FirstName || user.LastName != DBuser.LastName {
 			t.Error(err)
 		}
+		if !user.CheckPasswordHarsh("password") {
+			t.Error("updating the profile changed the password")
+		}
 		// Delete user
-		err = svc.DeleteUser(user.Id)
+		err = svc.DeleteUser(ctx, user.Id)
 		if err != nil {
 			t.Error(err)
 		}
 	})
+	t.Run("Set user role", func(t *testing.T) {
+		newUser := domain.User{
+			Email:    "roles@gmail.com",
+			Password: "password",
+			Role:     domain.RoleAdmin,
+		}
+		user, err := svc.CreateUser(ctx, &newUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+		if user.Role != domain.RoleOwner {
+			t.Errorf("new user has role %s, want %s", user.Role, domain.RoleOwner)
+		}
+
+		user.Role = domain.RoleAdmin
+		user, err = svc.UpdateUser(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Role != domain.RoleOwner {
+			t.Errorf("updating a user changed the role to %s", user.Role)
+		}
+
+		user, err = svc.SetUserRole(ctx, user.Id, domain.RoleViewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Role != domain.RoleViewer {
+			t.Errorf("user has role %s, want %s", user.Role, domain.RoleViewer)
+		}
+		if _, err := svc.SetUserRole(ctx, user.Id, "superuser"); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("setting an unknown role returned %v, want %v", err, domain.ErrValidation)
+		}
+	})
+	t.Run("Rotate refresh token", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "refresh@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		first, err := svc.IssueRefreshToken(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userID, second, err := svc.RotateRefreshToken(ctx, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID != user.Id {
+			t.Errorf("refresh token belongs to %s, want %s", userID, user.Id)
+		}
+		if second == first {
+			t.Error("rotating a refresh token returned the same token")
+		}
+
+		// Using the first token again revokes the whole family
+		if _, _, err := svc.RotateRefreshToken(ctx, first); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("reusing a refresh token returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		if _, _, err := svc.RotateRefreshToken(ctx, second); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("rotating a refresh token of a revoked family returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		if _, _, err := svc.RotateRefreshToken(ctx, "not a token"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("rotating an unknown refresh token returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+	})
+	t.Run("Reset password", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "forgot@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		if err := svc.ForgotPassword(ctx, "nobody@gmail.com"); err != nil {
+			t.Errorf("forgetting the password of an unknown email: %v", err)
+		}
+		if mailbox.count() != 0 {
+			t.Error("mailed a password reset link to an unknown email")
+		}
+
+		if err := svc.ForgotPassword(ctx, user.Email); err != nil {
+			t.Fatal(err)
+		}
+		mail := mailbox.last()
+		if mail == nil || mail.To != user.Email {
+			t.Fatalf("password reset link was not mailed to %s", user.Email)
+		}
+		token := linkToken(t, mail, "reset-password")
+		stolen, err := svc.IssueRefreshToken(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := svc.ResetPassword(ctx, token, "new password"); err != nil {
+			t.Fatal(err)
+		}
+		// Resetting the password logs every session out
+		if _, _, err := svc.RotateRefreshToken(ctx, stolen); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("rotating a refresh token issued before the reset returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		res, err := svc.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.CheckPasswordHarsh("new password") {
+			t.Error("password was not changed")
+		}
+		// Reset tokens can be used once
+		if err := svc.ResetPassword(ctx, token, "another password"); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("reusing a password reset token returned %v, want %v", err, domain.ErrValidation)
+		}
+	})
+	t.Run("Verify email", func(t *testing.T) {
+		strict := options
+		strict.RequireVerifiedEmail = VerifyForLogin
+		svc := NewPortfolioService(&repo, mailbox, strict)
+
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "verify@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+		if user.EmailVerified {
+			t.Fatal("new user starts with a verified email")
+		}
+
+		if _, err := svc.Authenticate(ctx, user.Email, "password", "192.0.2.1"); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("logging in before verifying returned %v, want %v", err, domain.ErrForbidden)
+		}
+		if _, err := svc.CreateProject(ctx, &domain.Project{UserID: user.Id, Title: "Portfolio"}); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("creating a project before verifying returned %v, want %v", err, domain.ErrForbidden)
+		}
+
+		if err := svc.SendVerificationEmail(ctx, user.Email); err != nil {
+			t.Fatal(err)
+		}
+		mail := mailbox.last()
+		if mail == nil || mail.To != user.Email {
+			t.Fatalf("verification link was not mailed to %s", user.Email)
+		}
+		token := linkToken(t, mail, "verify-email")
+
+		if _, err := svc.VerifyEmail(ctx, token+"x"); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("verifying with a tampered token returned %v, want %v", err, domain.ErrValidation)
+		}
+		res, err := svc.VerifyEmail(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.EmailVerified {
+			t.Error("email was not verified")
+		}
+		if _, err := svc.Authenticate(ctx, user.Email, "password", "192.0.2.1"); err != nil {
+			t.Errorf("logging in after verifying: %v", err)
+		}
+
+		// A new address has to be verified again, with a new link
+		res.Email = "verify2@gmail.com"
+		if res, err = svc.UpdateUser(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+		if res.EmailVerified {
+			t.Error("changed email is still verified")
+		}
+		if _, err := svc.VerifyEmail(ctx, token); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("verifying the new email with the old link returned %v, want %v", err, domain.ErrValidation)
+		}
+	})
+	t.Run("Two-factor authentication", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "2fa@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		secret, uri, err := svc.EnrollTOTP(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(uri, "otpauth://totp/") || !strings.Contains(uri, "secret="+secret) {
+			t.Errorf("otpauth URI %q does not carry secret %s", uri, secret)
+		}
+		step := time.Now().Unix() / totpPeriod
+
+		if _, err := svc.ConfirmTOTP(ctx, user.Id, "000000x"); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("confirming with a wrong code returned %v, want %v", err, domain.ErrValidation)
+		}
+		codes, err := svc.ConfirmTOTP(ctx, user.Id, totpTestCode(t, secret, step))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(codes) != recoveryCodeCount {
+			t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+		}
+		res, err := svc.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.TOTPEnabled || res.RecoveryCodes[0] == codes[0] {
+			t.Error("two-factor login is off or recovery codes are stored as they are")
+		}
+
+		challenge := svc.LoginChallenge(res)
+		// The code used to confirm can't be used again
+		if _, err := svc.CompleteLogin(ctx, challenge, totpTestCode(t, secret, step), "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("reusing a code returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		if _, err := svc.CompleteLogin(ctx, challenge, totpTestCode(t, secret, step+1), "192.0.2.1"); err != nil {
+			t.Errorf("logging in with the next code: %v", err)
+		}
+		if _, err := svc.CompleteLogin(ctx, challenge, strings.ToUpper(codes[0]), "192.0.2.1"); err != nil {
+			t.Errorf("logging in with a recovery code: %v", err)
+		}
+		if _, err := svc.CompleteLogin(ctx, challenge, codes[0], "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("reusing a recovery code returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		if _, err := svc.CompleteLogin(ctx, svc.signedToken(verificationPurpose, time.Now().Add(time.Hour), user.Id, user.Email), codes[1], "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("completing a login with a verification token returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		// The user id is public, a challenge needs the password step as well
+		if _, err := svc.CompleteLogin(ctx, svc.signedToken(loginChallengePurpose, time.Now().Add(time.Hour), user.Id), codes[1], "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("completing a login with a challenge of only the user id returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+		changed := *res
+		changed.Password = "another hash"
+		if _, err := svc.CompleteLogin(ctx, svc.LoginChallenge(&changed), codes[1], "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("completing a login with a challenge of another password returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+
+		// Updating the profile leaves two-factor login alone
+		res.FirstName = "Antony"
+		res.TOTPEnabled = false
+		if _, err := svc.UpdateUser(ctx, res); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.DisableTOTP(ctx, user.Id, codes[1]); err != nil {
+			t.Fatal(err)
+		}
+		if res, _ = svc.ReadUser(ctx, user.Id); res.TOTPEnabled || res.TOTPSecret != "" || len(res.RecoveryCodes) != 0 {
+			t.Error("two-factor login was not turned off")
+		}
+	})
+	t.Run("Complete logins with one recovery code concurrently", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "2fa-race@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+		secret, _, err := svc.EnrollTOTP(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes, err := svc.ConfirmTOTP(ctx, user.Id, totpTestCode(t, secret, time.Now().Unix()/totpPeriod))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := svc.ReadUser(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		challenge := svc.LoginChallenge(res)
+
+		var wg sync.WaitGroup
+		logins := make(chan error, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := svc.CompleteLogin(ctx, challenge, codes[0], "192.0.2.2")
+				logins <- err
+			}()
+		}
+		wg.Wait()
+		close(logins)
+		succeeded := 0
+		for err := range logins {
+			if err == nil {
+				succeeded++
+			} else if !errors.Is(err, domain.ErrUnauthorized) && !errors.Is(err, domain.ErrTooManyRequests) {
+				t.Error(err)
+			}
+		}
+		if succeeded != 1 {
+			t.Errorf("%d logins got through with one recovery code, want 1", succeeded)
+		}
+	})
+	t.Run("Throttle failed logins", func(t *testing.T) {
+		throttled := options
+		throttled.LoginAttempts = repository.NewInMemoryLoginAttemptStore()
+		svc := NewPortfolioService(&repo, mailbox, throttled)
+
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "guess@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		guess := func(password, address string) error {
+			_, err := svc.Authenticate(ctx, user.Email, password, address)
+			return err
+		}
+		// Logging in forgets the failures of the account
+		for i := 0; i < accountLoginLimit.free-1; i++ {
+			if err := guess("wrong", "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+				t.Fatalf("wrong password returned %v, want %v", err, domain.ErrUnauthorized)
+			}
+		}
+		if err := guess("password", "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < accountLoginLimit.free; i++ {
+			if err := guess("wrong", "192.0.2.1"); !errors.Is(err, domain.ErrUnauthorized) {
+				t.Fatalf("wrong password returned %v, want %v", err, domain.ErrUnauthorized)
+			}
+		}
+		// The right password has to wait as well, from any address
+		err = guess("password", "198.51.100.1")
+		var domainErr *domain.Error
+		if !errors.As(err, &domainErr) || !errors.Is(err, domain.ErrTooManyRequests) || domainErr.RetryAfter < LoginBackoff || domainErr.RetryAfter > LoginBackoff+time.Second {
+			t.Errorf("login after %d failures returned %v, want %v after about %s", accountLoginLimit.free, err, domain.ErrTooManyRequests, LoginBackoff)
+		}
+	})
+	t.Run("Log in with an identity provider", func(t *testing.T) {
+		github := testIdentityProvider{}
+		withProviders := options
+		withProviders.IdentityProviders = map[string]ports.IdentityProvider{"github": github}
+		svc := NewPortfolioService(&repo, mailbox, withProviders)
+
+		if _, err := svc.IdentityProviderURL("gitlab", "state", "verifier"); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("unknown provider returned %v, want %v", err, domain.ErrNotFound)
+		}
+		url, err := svc.IdentityProviderURL("github", "state", "verifier")
+		if err != nil || url != "https://github.test/authorize?state=state" {
+			t.Errorf("provider URL is %q, %v", url, err)
+		}
+
+		// Users without an account get one with a verified email
+		github["new"] = &domain.ExternalIdentity{Subject: "1", Email: "octocat@gmail.com", EmailVerified: true, FirstName: "Octo"}
+		user, err := svc.LoginWithIdentityProvider(ctx, "github", "new", "verifier")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+		if !user.EmailVerified || user.FirstName != "Octo" || user.Role != domain.RoleOwner || !reflect.DeepEqual(user.Identities, []string{"github:1"}) {
+			t.Errorf("user created at login is %+v", user)
+		}
+		again, err := svc.LoginWithIdentityProvider(ctx, "github", "new", "verifier")
+		if err != nil || again.Id != user.Id {
+			t.Errorf("second login returned %+v, %v, want user %s", again, err, user.Id)
+		}
+
+		// Accounts are linked by verified email, once per provider
+		existing, err := svc.CreateUser(ctx, &domain.User{Email: "linked@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, existing.Id)
+		github["unverified"] = &domain.ExternalIdentity{Subject: "2", Email: "linked@gmail.com"}
+		if _, err := svc.LoginWithIdentityProvider(ctx, "github", "unverified", "verifier"); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("login with an unverified provider email returned %v, want %v", err, domain.ErrForbidden)
+		}
+		github["link"] = &domain.ExternalIdentity{Subject: "2", Email: "linked@gmail.com", EmailVerified: true}
+		if _, err := svc.LoginWithIdentityProvider(ctx, "github", "link", "verifier"); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("linking an account with an unverified email returned %v, want %v", err, domain.ErrForbidden)
+		}
+		existing.EmailVerified = true
+		if _, err := repo.UpdateUser(ctx, existing); err != nil {
+			t.Fatal(err)
+		}
+		linked, err := svc.LoginWithIdentityProvider(ctx, "github", "link", "verifier")
+		if err != nil || linked.Id != existing.Id || !reflect.DeepEqual(linked.Identities, []string{"github:2"}) {
+			t.Errorf("linking returned %+v, %v", linked, err)
+		}
+		// Linked identities log in by their subject, whatever their email is now
+		github["moved"] = &domain.ExternalIdentity{Subject: "2", Email: "elsewhere@gmail.com"}
+		if moved, err := svc.LoginWithIdentityProvider(ctx, "github", "moved", "verifier"); err != nil || moved.Id != existing.Id {
+			t.Errorf("login with a linked identity under another email returned %+v, %v, want user %s", moved, err, existing.Id)
+		}
+		github["takeover"] = &domain.ExternalIdentity{Subject: "3", Email: "linked@gmail.com", EmailVerified: true}
+		if _, err := svc.LoginWithIdentityProvider(ctx, "github", "takeover", "verifier"); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("login with a second GitHub account returned %v, want %v", err, domain.ErrForbidden)
+		}
+		if _, err := svc.LoginWithIdentityProvider(ctx, "github", "expired", "verifier"); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("login with an unknown code returned %v, want %v", err, domain.ErrUnauthorized)
+		}
+	})
+
+	t.Run("Certifications", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "certifications@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		if _, err := svc.CreateCertification(ctx, &domain.Certification{UserID: user.Id, Title: " "}); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("creating a certification without a title returned %v, want ErrValidation", err)
+		}
+		if _, err := svc.CreateCertification(ctx, &domain.Certification{UserID: user.Id, Title: "CKAD", IssuedDate: "June 2023"}); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("creating a certification issued %q returned %v, want ErrValidation", "June 2023", err)
+		}
+		older, err := svc.CreateCertification(ctx, &domain.Certification{UserID: user.Id, Title: "AWS Certified Developer", IssuedDate: "2022-03"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		newer, err := svc.CreateCertification(ctx, &domain.Certification{UserID: user.Id, Title: "CKAD", IssuedDate: "2023-06-15"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if older.Id == "" || older.Id == newer.Id {
+			t.Errorf("certifications were given ids %q and %q", older.Id, newer.Id)
+		}
+
+		// Updating the profile leaves the certifications alone
+		user.Title = "Golang Software Engineer"
+		if _, err := svc.UpdateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		certifications, err := svc.ReadCertifications(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(certifications) != 2 || certifications[0].Id != newer.Id || certifications[1].Id != older.Id {
+			t.Errorf("user has certifications %+v, want the newest first", certifications)
+		}
+
+		older.Institution = "Amazon Web Services"
+		if _, err := svc.UpdateCertification(ctx, older); err != nil {
+			t.Fatal(err)
+		}
+		res, err := svc.ReadCertification(ctx, user.Id, older.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Institution != "Amazon Web Services" {
+			t.Errorf("updated certification has institution %q", res.Institution)
+		}
+
+		other, err := svc.CreateUser(ctx, &domain.User{Email: "certifications-other@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, other.Id)
+		if err := svc.DeleteCertification(ctx, other.Id, older.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("deleting the certification of another user returned %v, want ErrNotFound", err)
+		}
+		if err := svc.DeleteCertification(ctx, user.Id, older.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.ReadCertification(ctx, user.Id, older.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading a deleted certification returned %v, want ErrNotFound", err)
+		}
+	})
+	t.Run("Experience and education timeline", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "timeline@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		invalid := []*domain.Experience{
+			{UserID: user.Id, Role: "Engineer", StartDate: "2020-01"},
+			{UserID: user.Id, Company: "Andela", StartDate: "2020-01"},
+			{UserID: user.Id, Company: "Andela", Role: "Engineer"},
+			{UserID: user.Id, Company: "Andela", Role: "Engineer", StartDate: "January 2020"},
+			{UserID: user.Id, Company: "Andela", Role: "Engineer", StartDate: "2020-01", EndDate: "2019-12"},
+			{UserID: user.Id, Company: "Andela", Role: "Engineer", StartDate: "2020-01", Achievements: []string{"Shipped\nthings"}},
+		}
+		for _, experience := range invalid {
+			if _, err := svc.CreateExperience(ctx, experience); !errors.Is(err, domain.ErrValidation) {
+				t.Errorf("creating experience %+v returned %v, want ErrValidation", experience, err)
+			}
+		}
+		past, err := svc.CreateExperience(ctx, &domain.Experience{UserID: user.Id, Company: " Safaricom ", Role: "Backend Engineer", StartDate: "2019-01", EndDate: "2019-01-31", Achievements: []string{" Moved billing to Go ", ""}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if past.Company != "Safaricom" || len(past.Achievements) != 1 || past.Achievements[0] != "Moved billing to Go" {
+			t.Errorf("created experience %+v, want trimmed company and achievements", past)
+		}
+		current, err := svc.CreateExperience(ctx, &domain.Experience{UserID: user.Id, Company: "Andela", Role: "Software Engineer", StartDate: "2019-02"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if past.Id == "" || past.Id == current.Id {
+			t.Errorf("experiences were given ids %q and %q", past.Id, current.Id)
+		}
+
+		if _, err := svc.CreateEducation(ctx, &domain.Education{UserID: user.Id, Institution: "University of Nairobi", StartDate: "2014-09"}); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("creating education without a degree returned %v, want ErrValidation", err)
+		}
+		degree, err := svc.CreateEducation(ctx, &domain.Education{UserID: user.Id, Institution: "University of Nairobi", Degree: "BSc", StartDate: "2014-09", EndDate: "2018-06"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Updating the profile leaves the timeline alone
+		user.Title = "Golang Software Engineer"
+		if _, err := svc.UpdateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		experiences, err := svc.ReadExperiences(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(experiences) != 2 || experiences[0].Id != current.Id || experiences[1].Id != past.Id {
+			t.Errorf("user has experiences %+v, want the current job first", experiences)
+		}
+		education, err := svc.ReadEducations(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(education) != 1 || education[0].Id != degree.Id {
+			t.Errorf("user has education %+v, want %+v", education, degree)
+		}
+
+		current.EndDate = "2018-01"
+		if _, err := svc.UpdateExperience(ctx, current); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("ending an experience before it started returned %v, want ErrValidation", err)
+		}
+		if err := svc.DeleteEducation(ctx, user.Id, degree.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.ReadEducation(ctx, user.Id, degree.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("reading deleted education returned %v, want ErrNotFound", err)
+		}
+	})
+	t.Run("API keys", func(t *testing.T) {
+		user, err := svc.CreateUser(ctx, &domain.User{Email: "apikeys@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, user.Id)
+
+		if _, _, err := svc.CreateAPIKey(ctx, user.Id, "CI", []string{"projects:fly"}, time.Time{}); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("creating a key with an unknown scope returned %v, want ErrValidation", err)
+		}
+		if _, _, err := svc.CreateAPIKey(ctx, user.Id, "CI", []string{"projects:write"}, time.Now().Add(-time.Hour)); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("creating an expired key returned %v, want ErrValidation", err)
+		}
+
+		key, apiKey, err := svc.CreateAPIKey(ctx, user.Id, "CI", []string{"projects:write", "projects:write"}, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(key, APIKeyPrefix) || strings.Contains(apiKey.Hash, key) {
+			t.Errorf("key %q is not prefixed or is stored as is", key)
+		}
+		if !reflect.DeepEqual(apiKey.Scopes, []string{"projects:write"}) {
+			t.Errorf("key has scopes %v, want [projects:write]", apiKey.Scopes)
+		}
+		keys, err := svc.ReadAPIKeys(ctx, user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0].Id != apiKey.Id {
+			t.Errorf("user has keys %v, want %s", keys, apiKey.Id)
+		}
+
+		owner, stored, err := svc.AuthenticateAPIKey(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner.Id != user.Id || stored.Id != apiKey.Id {
+			t.Errorf("key authenticated user %s with key %s", owner.Id, stored.Id)
+		}
+		for _, invalid := range []string{"", "token", apiKey.Id, apiKey.Id + ".secret", key + "x"} {
+			if _, _, err := svc.AuthenticateAPIKey(ctx, invalid); !errors.Is(err, domain.ErrUnauthorized) {
+				t.Errorf("authenticating %q returned %v, want ErrUnauthorized", invalid, err)
+			}
+		}
+
+		expiring, expiringKey, err := svc.CreateAPIKey(ctx, user.Id, "Deploy", []string{"projects:delete"}, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expiringKey.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		repo.DeleteAPIKey(ctx, expiringKey.Id)
+		repo.CreateAPIKey(ctx, expiringKey)
+		if _, _, err := svc.AuthenticateAPIKey(ctx, expiring); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("authenticating an expired key returned %v, want ErrUnauthorized", err)
+		}
+
+		other, err := svc.CreateUser(ctx, &domain.User{Email: "apikeys-other@gmail.com", Password: "password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer svc.DeleteUser(ctx, other.Id)
+		if err := svc.RevokeAPIKey(ctx, other.Id, apiKey.Id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("revoking the key of another user returned %v, want ErrNotFound", err)
+		}
+		if err := svc.RevokeAPIKey(ctx, user.Id, apiKey.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := svc.AuthenticateAPIKey(ctx, key); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("authenticating a revoked key returned %v, want ErrUnauthorized", err)
+		}
+	})
 	t.Run("Delete user", func(t *testing.T) {
 		newUser := domain.User{
 			FirstName: "Antony",
@@ -126,22 +795,22 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		user, err := svc.CreateUser(&newUser)
+		user, err := svc.CreateUser(ctx, &newUser)
 		if err != nil {
 			t.Error(err)
 		}
 
-		err = svc.DeleteUser(user.Id)
+		err = svc.DeleteUser(ctx, user.Id)
 		if err != nil {
 			t.Error(err)
 		}
 
-		_, err = svc.ReadUserWithEmail(user.Email)
+		_, err = svc.ReadUserWithEmail(ctx, user.Email)
 		if err == nil {
 			t.Error(err)
 		}
 		// Delete None exising user
-		err = svc.DeleteUser(user.Id)
+		err = svc.DeleteUser(ctx, user.Id)
 		if err == nil {
 			t.Log("Delete none existing user test successful")
 		}
@@ -156,7 +825,7 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		user, err := svc.CreateUser(&newUser)
+		user, err := svc.CreateUser(ctx, &newUser)
 		user.Password = ""
 		user.Projects = nil
 		if err != nil {
@@ -172,7 +841,7 @@ func TestApplicationService(t *testing.T) {
 			Rate:      5,
 		}
 
-		project, err := svc.CreateProject(&newProject)
+		project, err := svc.CreateProject(ctx, &newProject)
 		if err != nil {
 			t.Error(err)
 		}
@@ -182,16 +851,16 @@ func TestApplicationService(t *testing.T) {
 		}
 
 		// Delete user
-		// err = svc.DeleteProject(project.Id)
-		// if err != nil {
-		// 	t.Error(err)
-		// }
+		err = svc.DeleteProject(ctx, project.Id)
+		if err != nil {
+			t.Error(err)
+		}
 
-		// // Delete user
-		// err = svc.DeleteUser(user.Id)
-		// if err != nil {
-		// 	t.Error(err)
-		// }
+		// Delete user
+		err = svc.DeleteUser(ctx, user.Id)
+		if err != nil {
+			t.Error(err)
+		}
 
 	})
 	t.Run("Read project with id", func(t *testing.T) {
@@ -203,9 +872,9 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		_, err := svc.ReadUserWithEmail(newUser.Email)
+		_, err := svc.ReadUserWithEmail(ctx, newUser.Email)
 		if err != nil {
-			user, err := svc.CreateUser(&newUser)
+			user, err := svc.CreateUser(ctx, &newUser)
 			if err != nil {
 				t.Error(err)
 			}
@@ -218,12 +887,12 @@ func TestApplicationService(t *testing.T) {
 				Rate:      5,
 			}
 
-			DBproject, err := svc.CreateProject(&newProject)
+			DBproject, err := svc.CreateProject(ctx, &newProject)
 			if err != nil {
 				t.Error(err)
 			}
 
-			project, err := svc.ReadProject(DBproject.Id)
+			project, err := svc.ReadProject(ctx, DBproject.Id)
 			if err != nil {
 				t.Error(err)
 			}
@@ -232,13 +901,13 @@ func TestApplicationService(t *testing.T) {
 			}
 
 			// Delete user
-			err = svc.DeleteProject(project.Id)
+			err = svc.DeleteProject(ctx, project.Id)
 			if err != nil {
 				t.Error(err)
 			}
 
 			// Delete user
-			err = svc.DeleteUser(user.Id)
+			err = svc.DeleteUser(ctx, user.Id)
 			if err != nil {
 				t.Error(err)
 			}
@@ -247,7 +916,7 @@ func TestApplicationService(t *testing.T) {
 
 	})
 	t.Run("Read projects", func(t *testing.T) {
-		projects, err := svc.ReadProjects()
+		projects, _, err := svc.ReadProjects(ctx, 0, "")
 		if err != nil {
 			t.Error(err)
 		}
@@ -266,7 +935,7 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		user, err := svc.CreateUser(&newUser)
+		user, err := svc.CreateUser(ctx, &newUser)
 		if err != nil {
 			t.Error(err)
 		}
@@ -279,26 +948,26 @@ func TestApplicationService(t *testing.T) {
 			Rate:      5,
 		}
 
-		DBproject, err := svc.CreateProject(&newProject)
+		DBproject, err := svc.CreateProject(ctx, &newProject)
 		if err != nil {
 			t.Error(err)
 		}
 		DBproject.Title = "Master gRPC for beginners"
 		DBproject.Body = "This tutorial provides a basic Go programmer’s introduction to working with gRPC.\nOur example is a simple route mapping application that lets clients get information about features on their route, create a summary of their route, and exchange route information such as traffic updates with the server and other clients."
 
-		project, err := svc.UpdateProject(DBproject)
+		project, err := svc.UpdateProject(ctx, DBproject)
 		if project.Title != DBproject.Title || project.Body != DBproject.Body {
 			t.Error(err)
 		}
 
 		// Delete user
-		err = svc.DeleteProject(project.Id)
+		err = svc.DeleteProject(ctx, project.Id)
 		if err != nil {
 			t.Error(err)
 		}
 
 		// Delete user
-		err = svc.DeleteUser(user.Id)
+		err = svc.DeleteUser(ctx, user.Id)
 		if err != nil {
 			t.Error(err)
 		}
@@ -312,7 +981,7 @@ func TestApplicationService(t *testing.T) {
 			Password:  "password",
 			Projects:  nil,
 		}
-		user, err := svc.CreateUser(&newUser)
+		user, err := svc.CreateUser(ctx, &newUser)
 		if err != nil {
 			t.Error(err)
 		}
@@ -325,11 +994,11 @@ func TestApplicationService(t *testing.T) {
 			Rate:      5,
 		}
 
-		DBproject, err := svc.CreateProject(&newProject)
+		DBproject, err := svc.CreateProject(ctx, &newProject)
 		if err != nil {
 			t.Error(err)
 		}
-		err = svc.DeleteProject(DBproject.Id)
+		err = svc.DeleteProject(ctx, DBproject.Id)
 		if err != nil {
 			t.Error(err)
 		}
@@ -337,26 +1006,26 @@ func TestApplicationService(t *testing.T) {
 	})
 	t.Run("Delete all test entiities", func(t *testing.T) {
 		// Delete user entities
-		// users, err := svc.ReadUsers()
+		// users, _, err := svc.ReadUsers(ctx, 0, "")
 		// if err != nil {
 		// 	t.Error(err)
 		// }
 
 		// for _, user := range users {
-		// 	err := svc.DeleteUser(user.Id)
+		// 	err := svc.DeleteUser(ctx, user.Id)
 		// 	if err != nil {
 		// 		t.Error(err)
 		// 	}
 		// }
 
 		// // Delete project entities
-		// projects, err := svc.ReadProjects()
+		// projects, _, err := svc.ReadProjects(ctx, 0, "")
 		// if err != nil {
 		// 	t.Error(err)
 		// }
 
 		// for _, project := range projects {
-		// 	err := svc.DeleteProject(project.Id)
+		// 	err := svc.DeleteProject(ctx, project.Id)
 		// 	if err != nil {
 		// 		t.Error(err)
 		// 	}
@@ -364,3 +1033,110 @@ func TestApplicationService(t *testing.T) {
 	})
 
 }
+
+func TestLoginLimitWait(t *testing.T) {
+	for _, test := range []struct {
+		limit    loginLimit
+		failures int
+		want     time.Duration
+	}{
+		{accountLoginLimit, 0, 0},
+		{accountLoginLimit, 2, 0},
+		{accountLoginLimit, 3, time.Second},
+		{accountLoginLimit, 4, 2 * time.Second},
+		{accountLoginLimit, 9, 64 * time.Second},
+		{accountLoginLimit, 10, LoginLockout},
+		{addressLoginLimit, 9, 0},
+		{addressLoginLimit, 19, 512 * time.Second},
+		{addressLoginLimit, 49, LoginLockout},
+		{addressLoginLimit, 1000, LoginLockout},
+	} {
+		if got := test.limit.wait(test.failures); got != test.want {
+			t.Errorf("%s wait after %d failures = %s, want %s", test.limit.prefix, test.failures, got, test.want)
+		}
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors of RFC 6238 for SHA1, cut to six digits
+	key := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	} {
+		if got := totpCode(key, unix/totpPeriod); got != want {
+			t.Errorf("totpCode at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+// totpTestCode returns the code of secret for a time step, as an
+// authenticator app would show it.
+func totpTestCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, step)
+}
+
+// testMailer keeps the emails the service sends.
+type testMailer struct {
+	mu    sync.Mutex
+	mails []*domain.Mail
+}
+
+func (m *testMailer) Send(ctx context.Context, mail *domain.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func (m *testMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.mails)
+}
+
+func (m *testMailer) last() *domain.Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.mails) == 0 {
+		return nil
+	}
+	return m.mails[len(m.mails)-1]
+}
+
+// testIdentityProvider logs in the identity of each code.
+type testIdentityProvider map[string]*domain.ExternalIdentity
+
+func (p testIdentityProvider) AuthCodeURL(state, verifier string) string {
+	return "https://github.test/authorize?state=" + state
+}
+
+func (p testIdentityProvider) Exchange(ctx context.Context, code, verifier string) (*domain.ExternalIdentity, error) {
+	identity, ok := p[code]
+	if !ok {
+		return nil, domain.NewError(domain.ErrUnauthorized, "unknown code")
+	}
+	copy := *identity
+	return &copy, nil
+}
+
+// linkToken returns the token in the link to page in an email.
+func linkToken(t *testing.T, mail *domain.Mail, page string) string {
+	t.Helper()
+	match := regexp.MustCompile(regexp.QuoteMeta(page) + `\?token=(\S+)`).FindStringSubmatch(mail.Body)
+	if match == nil {
+		t.Fatalf("no %s link in %q", page, mail.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
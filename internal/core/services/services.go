@@ -9,8 +9,10 @@ Description :
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/AntonyIS/portfolio-be/internal/core/domain"
@@ -19,78 +21,183 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Options configures a PortfolioService.
+type Options struct {
+	// AppURL is where the frontend is served, links in emails point there
+	AppURL string
+	// SecretKey signs the links in email verification emails and the
+	// two-factor login challenges, it must not be empty
+	SecretKey []byte
+	// RequireVerifiedEmail is what users can't do before verifying their
+	// email, one of VerifyNone, VerifyForProjects or VerifyForLogin
+	RequireVerifiedEmail string
+	// LoginAttempts counts failed logins, the repository does when it's nil
+	LoginAttempts ports.LoginAttemptStore
+	// IdentityProviders users can log in with, by name
+	IdentityProviders map[string]ports.IdentityProvider
+}
+
+var errInvalidCredentials = domain.NewError(domain.ErrUnauthorized, "Invalid email or password")
+
 type PortfolioService struct {
-	repo ports.PortfolioRepository
+	repo     ports.PortfolioRepository
+	mailer   ports.Mailer
+	appURL   string
+	attempts ports.LoginAttemptStore
+	options  Options
 }
 
-func NewPortfolioService(repo *ports.PortfolioRepository) *PortfolioService {
+func NewPortfolioService(repo *ports.PortfolioRepository, mailer ports.Mailer, options Options) *PortfolioService {
+	attempts := options.LoginAttempts
+	if attempts == nil {
+		attempts = *repo
+	}
 	return &PortfolioService{
-		repo: *repo,
+		repo:     *repo,
+		mailer:   mailer,
+		appURL:   strings.TrimSuffix(options.AppURL, "/"),
+		attempts: attempts,
+		options:  options,
 	}
 }
 
-func (svc *PortfolioService) CreateUser(user *domain.User) (*domain.User, error) {
+// normalizeEmail returns email the way it is stored and looked up, so
+// addresses that differ only in case or surrounding spaces are one account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (svc *PortfolioService) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	user.Email = normalizeEmail(user.Email)
+	if user.Email == "" || user.Password == "" {
+		return nil, domain.NewError(domain.ErrValidation, "email and password are required")
+	}
 	// Check if user already exist in the database
-	// Get all users
-	users, err := svc.repo.ReadUsers()
-	if err != nil {
-		return nil, err
+	// The repository enforces uniqueness as well, this only saves hashing
+	// the password of a user that can't be created
+	_, err := svc.repo.ReadUserWithEmail(ctx, user.Email)
+	if err == nil {
+		// User found, return error message
+		return nil, domain.ErrEmailTaken
 	}
-	// Loop through current users and search for user with email
-	for _, item := range users {
-		if item.Email == user.Email {
-			// User found, return error message
-			return nil, errors.New("user with email exists!")
-		}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
 	}
 	user.Id = uuid.New().String()
+	// Roles are granted with SetUserRole, never picked at sign up
+	user.Role = domain.RoleOwner
+	// Two-factor authentication is turned on with EnrollTOTP and ConfirmTOTP
+	user.TOTPEnabled = false
+	// The email is verified with the link sent by SendVerificationEmail
+	user.EmailVerified = false
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(user.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 
-	return svc.repo.CreateUser(user)
+	return svc.repo.CreateUser(ctx, user)
 }
 
-func (svc *PortfolioService) ReadUser(id string) (*domain.User, error) {
-	return svc.repo.ReadUser(id)
-}
-
-func (svc *PortfolioService) ReadUserWithEmail(email string) (*domain.User, error) {
-	users, err := svc.repo.ReadUsers()
+// Authenticate returns the user with email if password is theirs. Failures
+// are counted for the account and for address, the client address, and
+// after too many the login fails with ErrTooManyRequests before the password
+// is checked.
+func (svc *PortfolioService) Authenticate(ctx context.Context, email, password, address string) (*domain.User, error) {
+	email = normalizeEmail(email)
+	keys := loginKeys(email, address)
+	if err := svc.checkLoginAttempts(ctx, keys); err != nil {
+		return nil, err
+	}
+	user, err := svc.repo.ReadUserWithEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		// Don't tell which emails have an account
+		return nil, svc.loginFailed(ctx, keys, errInvalidCredentials)
+	}
 	if err != nil {
 		return nil, err
 	}
-	for _, user := range users {
-		if user.Email == email {
-			return user, nil
+	if !user.CheckPasswordHarsh(password) {
+		return nil, svc.loginFailed(ctx, keys, errInvalidCredentials)
+	}
+	// With two-factor login on the count goes on until the code is right too
+	if !user.TOTPEnabled {
+		if err := svc.loginSucceeded(ctx, keys); err != nil {
+			return nil, err
 		}
 	}
-	return nil, errors.New("user with id not found!")
+	if svc.options.RequireVerifiedEmail == VerifyForLogin && !user.EmailVerified {
+		return nil, errEmailNotVerified
+	}
+	return user, nil
+}
+
+func (svc *PortfolioService) ReadUser(ctx context.Context, id string) (*domain.User, error) {
+	return svc.repo.ReadUser(ctx, id)
+}
+
+func (svc *PortfolioService) ReadUserWithEmail(ctx context.Context, email string) (*domain.User, error) {
+	return svc.repo.ReadUserWithEmail(ctx, normalizeEmail(email))
 }
 
-func (svc *PortfolioService) ReadUsers() ([]*domain.User, error) {
-	return svc.repo.ReadUsers()
+func (svc *PortfolioService) ReadUsers(ctx context.Context, limit int, cursor string) ([]*domain.User, string, error) {
+	return svc.repo.ReadUsers(ctx, limit, cursor)
 }
 
-func (svc *PortfolioService) UpdateUser(user *domain.User) (*domain.User, error) {
-	return svc.repo.UpdateUser(user)
+func (svc *PortfolioService) UpdateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	// Check if user exists
+	existing, err := svc.repo.ReadUser(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	// Users can't change their own role
+	user.Role = existing.Role
+	// Passwords are changed with ResetPassword and projects with the project
+	// methods, the user only carries the profile
+	user.Password = existing.Password
+	user.Projects = existing.Projects
+	// Certifications, experience and education are changed with their own methods
+	user.Certifications = existing.Certifications
+	user.Experiences = existing.Experiences
+	user.Education = existing.Education
+	// A new email has to be verified again
+	user.Email = normalizeEmail(user.Email)
+	user.EmailVerified = existing.EmailVerified && strings.EqualFold(user.Email, existing.Email)
+	// Two-factor settings are changed by the TOTP methods only
+	user.TOTPEnabled = existing.TOTPEnabled
+	user.TOTPSecret = existing.TOTPSecret
+	user.TOTPLastStep = existing.TOTPLastStep
+	user.RecoveryCodes = existing.RecoveryCodes
+	// Identities are linked by logging in with them
+	user.Identities = existing.Identities
+	return svc.repo.UpdateUser(ctx, user)
 }
 
-func (svc *PortfolioService) DeleteUser(id string) error {
+func (svc *PortfolioService) SetUserRole(ctx context.Context, id string, role domain.Role) (*domain.User, error) {
+	if !role.Valid() {
+		return nil, domain.NewError(domain.ErrValidation, "unknown role %q", role)
+	}
+	user, err := svc.repo.ReadUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return svc.repo.UpdateUser(ctx, user)
+}
+
+func (svc *PortfolioService) DeleteUser(ctx context.Context, id string) error {
 	// Check if user exists
-	_, err := svc.ReadUser(id)
+	_, err := svc.ReadUser(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return svc.repo.DeleteUser(id)
+	return svc.repo.DeleteUser(ctx, id)
 }
 
-func (svc *PortfolioService) CreateProject(project *domain.Project) (*domain.Project, error) {
+func (svc *PortfolioService) CreateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
 	// Create Project ID
 	project.Id = uuid.New().String()
 	// Create project created at timestamp
@@ -99,44 +206,55 @@ func (svc *PortfolioService) CreateProject(project *domain.Project) (*domain.Pro
 	userID := project.UserID
 
 	// Get user with id
-	user, err := svc.ReadUser(userID)
+	user, err := svc.ReadUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	if svc.options.RequireVerifiedEmail != VerifyNone && !user.EmailVerified {
+		return nil, errEmailNotVerified
+	}
 
 	project.UserName = fmt.Sprintf("%s %s", user.FirstName, user.LastName)
 	project.UserTitle = fmt.Sprintf("%s ", user.Title)
 	// Add the new project to user
 	user.Projects = append(user.Projects, project)
 	// Save the new changes for user
-	svc.repo.UpdateUser(user)
+	_, err = svc.repo.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
 	// Add the new project into the database
-	return svc.repo.CreateProject(project)
+	return svc.repo.CreateProject(ctx, project)
 
 }
 
-func (svc *PortfolioService) ReadProject(id string) (*domain.Project, error) {
-	return svc.repo.ReadProject(id)
+func (svc *PortfolioService) ReadProject(ctx context.Context, id string) (*domain.Project, error) {
+	return svc.repo.ReadProject(ctx, id)
 }
 
-func (svc *PortfolioService) ReadProjects() ([]*domain.Project, error) {
-	return svc.repo.ReadProjects()
+func (svc *PortfolioService) ReadProjects(ctx context.Context, limit int, cursor string) ([]*domain.Project, string, error) {
+	return svc.repo.ReadProjects(ctx, limit, cursor)
 }
 
-func (svc *PortfolioService) UpdateProject(project *domain.Project) (*domain.Project, error) {
-	return svc.repo.UpdateProject(project)
+func (svc *PortfolioService) UpdateProject(ctx context.Context, project *domain.Project) (*domain.Project, error) {
+	// Check if project exists
+	_, err := svc.repo.ReadProject(ctx, project.Id)
+	if err != nil {
+		return nil, err
+	}
+	return svc.repo.UpdateProject(ctx, project)
 }
 
-func (svc *PortfolioService) DeleteProject(id string) error {
+func (svc *PortfolioService) DeleteProject(ctx context.Context, id string) error {
 	// Get the project to delete
-	project, err := svc.repo.ReadProject(id)
+	project, err := svc.repo.ReadProject(ctx, id)
 
 	if err != nil {
 		return err
 	}
 
 	// Delete project
-	err = svc.repo.DeleteProject(id)
+	err = svc.repo.DeleteProject(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -144,26 +262,31 @@ func (svc *PortfolioService) DeleteProject(id string) error {
 	// Get the user ID from the project
 	userID := project.UserID
 	// Get user with the userID
-	user, err := svc.repo.ReadUser(userID)
+	user, err := svc.repo.ReadUser(ctx, userID)
 
 	if err != nil {
 		return err
 	}
-	// Loop through current project of the user and get the project to delete
+	// Loop through current project of the user and get the project to delete.
+	// Repositories that keep projects in their own table have already
+	// dropped it from the list.
 	for index, item := range user.Projects {
 		if item.Id == id {
 			// Delete project from user projects list
 			user.Projects = append(user.Projects[:index], user.Projects[index+1:]...)
 			// Update the user
-			_, err := svc.repo.UpdateUser(user)
-
-			if err != nil {
-				return err
-			}
-			// Delete the project from the database
-			return svc.repo.DeleteProject(id)
+			_, err := svc.repo.UpdateUser(ctx, user)
+			return err
 		}
 	}
 
-	return errors.New("Internal server error: Unable to delete project")
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
 }
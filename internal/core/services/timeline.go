@@ -0,0 +1,141 @@
+/*
+Package name : services
+File name : timeline.go
+Author : Antony Injila
+Description :
+	- Host the logic for the work experience and education of a user
+	- Entries are always read and changed through their user, the entry of
+	  another user is as good as missing
+*/
+
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+func (svc *PortfolioService) CreateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	if err := validateExperience(experience); err != nil {
+		return nil, err
+	}
+	experience.Id = uuid.New().String()
+	return svc.repo.CreateExperience(ctx, experience)
+}
+
+func (svc *PortfolioService) ReadExperience(ctx context.Context, userID, id string) (*domain.Experience, error) {
+	return svc.repo.ReadExperience(ctx, userID, id)
+}
+
+func (svc *PortfolioService) ReadExperiences(ctx context.Context, userID string) ([]*domain.Experience, error) {
+	return svc.repo.ReadExperiences(ctx, userID)
+}
+
+func (svc *PortfolioService) UpdateExperience(ctx context.Context, experience *domain.Experience) (*domain.Experience, error) {
+	if err := validateExperience(experience); err != nil {
+		return nil, err
+	}
+	return svc.repo.UpdateExperience(ctx, experience)
+}
+
+func (svc *PortfolioService) DeleteExperience(ctx context.Context, userID, id string) error {
+	return svc.repo.DeleteExperience(ctx, userID, id)
+}
+
+func (svc *PortfolioService) CreateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	if err := validateEducation(education); err != nil {
+		return nil, err
+	}
+	education.Id = uuid.New().String()
+	return svc.repo.CreateEducation(ctx, education)
+}
+
+func (svc *PortfolioService) ReadEducation(ctx context.Context, userID, id string) (*domain.Education, error) {
+	return svc.repo.ReadEducation(ctx, userID, id)
+}
+
+func (svc *PortfolioService) ReadEducations(ctx context.Context, userID string) ([]*domain.Education, error) {
+	return svc.repo.ReadEducations(ctx, userID)
+}
+
+func (svc *PortfolioService) UpdateEducation(ctx context.Context, education *domain.Education) (*domain.Education, error) {
+	if err := validateEducation(education); err != nil {
+		return nil, err
+	}
+	return svc.repo.UpdateEducation(ctx, education)
+}
+
+func (svc *PortfolioService) DeleteEducation(ctx context.Context, userID, id string) error {
+	return svc.repo.DeleteEducation(ctx, userID, id)
+}
+
+// validateExperience checks what the user entered. Achievements are stored
+// one per line by the SQL store, so they may not span lines.
+func validateExperience(experience *domain.Experience) error {
+	experience.Company = strings.TrimSpace(experience.Company)
+	experience.Role = strings.TrimSpace(experience.Role)
+	if experience.Company == "" {
+		return domain.NewError(domain.ErrValidation, "company is required")
+	}
+	if experience.Role == "" {
+		return domain.NewError(domain.ErrValidation, "role is required")
+	}
+	if err := validateTimelineDates(experience.StartDate, experience.EndDate); err != nil {
+		return err
+	}
+	var achievements []string
+	for _, achievement := range experience.Achievements {
+		achievement = strings.TrimSpace(achievement)
+		if achievement == "" {
+			continue
+		}
+		if strings.ContainsAny(achievement, "\r\n") {
+			return domain.NewError(domain.ErrValidation, "achievement %q spans more than one line", achievement)
+		}
+		achievements = append(achievements, achievement)
+	}
+	experience.Achievements = achievements
+	return nil
+}
+
+// validateEducation checks what the user entered.
+func validateEducation(education *domain.Education) error {
+	education.Institution = strings.TrimSpace(education.Institution)
+	education.Degree = strings.TrimSpace(education.Degree)
+	if education.Institution == "" {
+		return domain.NewError(domain.ErrValidation, "institution is required")
+	}
+	if education.Degree == "" {
+		return domain.NewError(domain.ErrValidation, "degree is required")
+	}
+	return validateTimelineDates(education.StartDate, education.EndDate)
+}
+
+// validateTimelineDates checks the dates of a timeline entry, which are
+// ordered as strings. An empty end date means the entry is ongoing.
+func validateTimelineDates(start, end string) error {
+	if start == "" {
+		return domain.NewError(domain.ErrValidation, "start_date is required")
+	}
+	if !isISODate(start) {
+		return domain.NewError(domain.ErrValidation, "start_date %q is not a date such as 2023-05 or 2023-05-14", start)
+	}
+	if end == "" {
+		return nil
+	}
+	if !isISODate(end) {
+		return domain.NewError(domain.ErrValidation, "end_date %q is not a date such as 2023-05 or 2023-05-14", end)
+	}
+	// Compare as much as both dates have, 2023-05 does not end before 2023-05-14
+	n := len(start)
+	if len(end) < n {
+		n = len(end)
+	}
+	if end[:n] < start[:n] {
+		return domain.NewError(domain.ErrValidation, "end_date %s is before start_date %s", end, start)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+/*
+Package name : services
+File name : tokens.go
+Author : Antony Injila
+Description :
+	- Host the logic for revoking access tokens and rotating refresh tokens
+	- A refresh token used twice was stolen, so its whole family is revoked
+	- Signed tokens carry their own data and expiry, they are checked with the
+	  secret key instead of being stored
+*/
+
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// RefreshTokenTTL is how long a refresh token can be exchanged for new tokens.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var errInvalidRefreshToken = domain.NewError(domain.ErrUnauthorized, "refresh token is invalid or expired")
+
+func (svc *PortfolioService) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	if id == "" {
+		return domain.NewError(domain.ErrValidation, "token has no id")
+	}
+	return svc.repo.RevokeToken(ctx, id, expiresAt)
+}
+
+func (svc *PortfolioService) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	return svc.repo.IsTokenRevoked(ctx, id)
+}
+
+// IssueRefreshToken starts a new family of refresh tokens for a user that
+// just logged in.
+func (svc *PortfolioService) IssueRefreshToken(ctx context.Context, userID string) (string, error) {
+	return svc.newRefreshToken(ctx, userID, uuid.New().String())
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one of the same
+// family and returns the id of the user it belongs to with the new token.
+func (svc *PortfolioService) RotateRefreshToken(ctx context.Context, token string) (string, string, error) {
+	stored, err := svc.repo.ReadRefreshToken(ctx, hashToken(token))
+	if errors.Is(err, domain.ErrNotFound) {
+		return "", "", errInvalidRefreshToken
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if stored.ExpiresAt <= time.Now().Unix() {
+		return "", "", errInvalidRefreshToken
+	}
+
+	if !stored.Used {
+		err = svc.repo.UseRefreshToken(ctx, stored.Id)
+	}
+	if stored.Used || errors.Is(err, domain.ErrConflict) {
+		// The token was rotated before, so either the client or whoever
+		// stole the token holds a newer one. Log both of them out.
+		if err := svc.repo.DeleteRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			return "", "", err
+		}
+		return "", "", domain.NewError(domain.ErrUnauthorized, "refresh token was used before, log in again")
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	next, err := svc.newRefreshToken(ctx, stored.UserID, stored.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	return stored.UserID, next, nil
+}
+
+// RevokeRefreshToken revokes every token of the family of token, unknown
+// tokens are ignored.
+func (svc *PortfolioService) RevokeRefreshToken(ctx context.Context, token string) error {
+	stored, err := svc.repo.ReadRefreshToken(ctx, hashToken(token))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return svc.repo.DeleteRefreshTokenFamily(ctx, stored.FamilyID)
+}
+
+func (svc *PortfolioService) newRefreshToken(ctx context.Context, userID, familyID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = svc.repo.CreateRefreshToken(ctx, &domain.RefreshToken{
+		Id:        hashToken(token),
+		FamilyID:  familyID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// newToken returns a random token to hand to a client.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the id a token is stored under, so a leaked database
+// doesn't leak usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signedToken signs fields with the key of one purpose, so a token can't be
+// used for another. The token is the payload and its signature,
+// both base64url encoded.
+func (svc *PortfolioService) signedToken(purpose string, expiresAt time.Time, fields ...string) string {
+	payload := []byte(strings.Join(append([]string{purpose, strconv.FormatInt(expiresAt.Unix(), 10)}, fields...), "\n"))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(svc.sign(purpose, payload))
+}
+
+// parseSignedToken returns the n fields of a token signed for purpose if its
+// signature is valid and it has not expired.
+func (svc *PortfolioService) parseSignedToken(purpose, token string, n int) ([]string, bool) {
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, svc.sign(purpose, payload)) {
+		return nil, false
+	}
+	// The last field may contain anything, the ones before it are ids
+	fields := strings.SplitN(string(payload), "\n", n+2)
+	if len(fields) != n+2 || fields[0] != purpose {
+		return nil, false
+	}
+	expiresAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || expiresAt <= time.Now().Unix() {
+		return nil, false
+	}
+	return fields[2:], true
+}
+
+// sign signs payload with the key derived from the secret key for purpose.
+// The secret key itself also signs HS256 access tokens, so it is never used
+// directly.
+func (svc *PortfolioService) sign(purpose string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, purposeKey(svc.options.SecretKey, purpose))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// purposeKey derives the key signing the tokens of purpose from secret.
+func purposeKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("portfolio-be signed token\n" + purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,292 @@
+/*
+Package name : services
+File name : totp.go
+Author : Antony Injila
+Description :
+	- Host the logic for two-factor authentication with RFC 6238 one-time passwords
+	- Users enroll with an authenticator app and confirm with a first code, which
+	  turns two-factor login on and returns the recovery codes, once
+	- With two-factor login on a password only gets a login challenge, which is
+	  completed with a code or with an unused recovery code
+*/
+
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+// LoginChallengeTTL is how long a user has to enter a code after the password.
+const LoginChallengeTTL = 5 * time.Minute
+
+const (
+	totpIssuer = "Portfolio"
+	totpDigits = 6
+	totpPeriod = 30
+	// Codes of the time steps next to the current one are accepted as well,
+	// for clocks that are a little off
+	totpSkew = 1
+
+	recoveryCodeCount     = 10
+	loginChallengePurpose = "login-2fa"
+)
+
+var (
+	errInvalidCode           = domain.NewError(domain.ErrValidation, "the code is invalid or was used before")
+	errInvalidLoginChallenge = domain.NewError(domain.ErrUnauthorized, "login challenge is invalid or expired, log in again")
+	errTOTPEnabled           = domain.NewError(domain.ErrConflict, "two-factor authentication is on already")
+	errTOTPDisabled          = domain.NewError(domain.ErrValidation, "two-factor authentication is not on")
+)
+
+// EnrollTOTP gives the user a new TOTP secret and returns it with the
+// otpauth URI authenticator apps read from a QR code. Two-factor login is
+// turned on by ConfirmTOTP.
+func (svc *PortfolioService) EnrollTOTP(ctx context.Context, userID string) (string, string, error) {
+	user, err := svc.repo.ReadUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", errTOTPEnabled
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	user.TOTPLastStep = 0
+	if _, err := svc.repo.UpdateUser(ctx, user); err != nil {
+		return "", "", err
+	}
+
+	params := url.Values{}
+	params.Set("secret", user.TOTPSecret)
+	params.Set("issuer", totpIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	uri := fmt.Sprintf("otpauth://totp/%s?%s", url.PathEscape(totpIssuer+":"+user.Email), params.Encode())
+	return user.TOTPSecret, uri, nil
+}
+
+// ConfirmTOTP turns two-factor login on once the user entered a code of the
+// secret from EnrollTOTP. It returns the recovery codes, only their hashes
+// are stored.
+func (svc *PortfolioService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := svc.repo.ReadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errTOTPEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, domain.NewError(domain.ErrValidation, "enroll in two-factor authentication first")
+	}
+	if ok, err := svc.useTOTP(ctx, user, code, time.Now()); err != nil || !ok {
+		if err == nil {
+			err = errInvalidCode
+		}
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	user.RecoveryCodes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+		user.RecoveryCodes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	user.TOTPEnabled = true
+	if _, err := svc.repo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor login off, which takes a code or a recovery
+// code as well.
+func (svc *PortfolioService) DisableTOTP(ctx context.Context, userID, code string) error {
+	user, err := svc.repo.ReadUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errTOTPDisabled
+	}
+	if ok, err := svc.useSecondFactor(ctx, user, code, time.Now()); err != nil || !ok {
+		if err == nil {
+			err = errInvalidCode
+		}
+		return err
+	}
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	_, err = svc.repo.UpdateUser(ctx, user)
+	return err
+}
+
+// LoginChallenge returns the token a user with two-factor login on sends
+// with a code to CompleteLogin, after Authenticate accepted the password.
+// It carries a fingerprint of the password hash, so a user id alone can't
+// make a challenge and changing the password voids the open ones.
+func (svc *PortfolioService) LoginChallenge(user *domain.User) string {
+	return svc.signedToken(loginChallengePurpose, time.Now().Add(LoginChallengeTTL), user.Id, svc.passwordFingerprint(user))
+}
+
+// passwordFingerprint identifies the password hash of user without giving
+// it away, tokens are signed but not encrypted.
+func (svc *PortfolioService) passwordFingerprint(user *domain.User) string {
+	return base64.RawURLEncoding.EncodeToString(svc.sign(loginChallengePurpose+"-password", []byte(user.Password)))
+}
+
+// CompleteLogin returns the user of the login challenge if code is a code of
+// their authenticator app or one of their unused recovery codes. Wrong codes
+// count as failed logins of the account and of address.
+func (svc *PortfolioService) CompleteLogin(ctx context.Context, challenge, code, address string) (*domain.User, error) {
+	fields, ok := svc.parseSignedToken(loginChallengePurpose, challenge, 2)
+	if !ok {
+		return nil, errInvalidLoginChallenge
+	}
+	user, err := svc.repo.ReadUser(ctx, fields[0])
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, errInvalidLoginChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled || !hmac.Equal([]byte(fields[1]), []byte(svc.passwordFingerprint(user))) {
+		return nil, errInvalidLoginChallenge
+	}
+	keys := loginKeys(user.Email, address)
+	if err := svc.checkLoginAttempts(ctx, keys); err != nil {
+		return nil, err
+	}
+	ok, err = svc.useSecondFactor(ctx, user, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, svc.loginFailed(ctx, keys, domain.NewError(domain.ErrUnauthorized, "the code is invalid or was used before"))
+	}
+	if err := svc.loginSucceeded(ctx, keys); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// useSecondFactor checks code as a TOTP code, or else as a recovery code,
+// and uses it up. The repository uses codes up with a conditional write,
+// so of two requests with one code only one gets true.
+func (svc *PortfolioService) useSecondFactor(ctx context.Context, user *domain.User, code string, now time.Time) (bool, error) {
+	if ok, err := svc.useTOTP(ctx, user, code, now); err != nil || ok {
+		return ok, err
+	}
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, stored := range user.RecoveryCodes {
+		if hmac.Equal([]byte(stored), []byte(hash)) {
+			err := svc.repo.UseRecoveryCode(ctx, user.Id, stored)
+			if errors.Is(err, domain.ErrConflict) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// useTOTP checks code as a TOTP code and records its time step as used.
+func (svc *PortfolioService) useTOTP(ctx context.Context, user *domain.User, code string, now time.Time) (bool, error) {
+	step, ok := checkTOTP(user, code, now)
+	if !ok {
+		return false, nil
+	}
+	err := svc.repo.UseTOTPStep(ctx, user.Id, step)
+	if errors.Is(err, domain.ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// checkTOTP returns the time step around now code is the code of for the
+// secret of user. A code can't be used twice, so codes of the last step
+// used and earlier ones are refused.
+func checkTOTP(user *domain.User, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(user.TOTPSecret))
+	if err != nil || len(key) == 0 {
+		return 0, false
+	}
+	step := now.Unix() / totpPeriod
+	for s := step - totpSkew; s <= step+totpSkew; s++ {
+		if s <= user.TOTPLastStep {
+			continue
+		}
+		if hmac.Equal([]byte(totpCode(key, s)), []byte(code)) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode is the HOTP value of RFC 4226 for the time step as counter.
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulo)
+}
+
+// newRecoveryCode returns a random code written as four groups of four
+// characters.
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,93 @@
+/*
+Package name : services
+File name : verification.go
+Author : Antony Injila
+Description :
+	- Host the logic for verifying the email of new users
+	- The link in the verification email is a signed token, nothing is stored
+*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+)
+
+// What users can't do before their email is verified
+const (
+	VerifyNone        = ""
+	VerifyForProjects = "projects"
+	VerifyForLogin    = "login"
+)
+
+// VerificationTTL is how long the link in a verification email works.
+const VerificationTTL = 48 * time.Hour
+
+const verificationPurpose = "verify-email"
+
+var (
+	errInvalidVerificationToken = domain.NewError(domain.ErrValidation, "verification token is invalid or expired")
+	errEmailNotVerified         = domain.NewError(domain.ErrForbidden, "verify your email address first")
+)
+
+// SendVerificationEmail mails a verification link to the user with email. It
+// does nothing for unknown emails and verified users, so it can't be used to
+// find accounts.
+func (svc *PortfolioService) SendVerificationEmail(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	if email == "" {
+		return domain.NewError(domain.ErrValidation, "email is required")
+	}
+	user, err := svc.repo.ReadUserWithEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	token := svc.signedToken(verificationPurpose, time.Now().Add(VerificationTTL), user.Id, user.Email)
+	link := fmt.Sprintf("%s/verify-email?token=%s", svc.appURL, url.QueryEscape(token))
+	return svc.mailer.Send(ctx, &domain.Mail{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below within %s to verify your email address:\n\n%s\n\nIf you did not create an account, ignore this email.\n",
+			user.FirstName, VerificationTTL, link),
+	})
+}
+
+// VerifyEmail marks the email of the user the verification token was mailed
+// to as verified. Verifying twice is not an error.
+func (svc *PortfolioService) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	fields, ok := svc.parseSignedToken(verificationPurpose, token, 2)
+	if !ok {
+		return nil, errInvalidVerificationToken
+	}
+	userID, email := fields[0], fields[1]
+	user, err := svc.repo.ReadUser(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, errInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The link was sent to an address the user has changed since
+	if !strings.EqualFold(user.Email, email) {
+		return nil, errInvalidVerificationToken
+	}
+	if user.EmailVerified {
+		return user, nil
+	}
+	user.EmailVerified = true
+	return svc.repo.UpdateUser(ctx, user)
+}
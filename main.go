@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"log"
 
 	"github.com/AntonyIS/portfolio-be/config"
 	"github.com/AntonyIS/portfolio-be/internal/adapters/http/gin"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/mailer"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/middleware"
+	"github.com/AntonyIS/portfolio-be/internal/adapters/oauth"
 	"github.com/AntonyIS/portfolio-be/internal/adapters/repository"
+	"github.com/AntonyIS/portfolio-be/internal/core/domain"
+	"github.com/AntonyIS/portfolio-be/internal/core/ports"
 	"github.com/AntonyIS/portfolio-be/internal/core/services"
 )
 
@@ -19,7 +27,90 @@ func init() {
 func main() {
 	flag.Parse()
 	config := config.NewConfiguration(env)
-	repo := repository.NewDynamoDBRepository(config)
-	svc := services.NewPortfolioService(&repo)
-	gin.InitGinRoutes(*svc, *config)
+
+	var repo ports.PortfolioRepository
+	switch config.Storage {
+	case "dynamodb":
+		if config.CreateTables {
+			if err := repository.CreateDynamoDBTables(context.Background(), config); err != nil {
+				log.Fatal(err)
+			}
+		}
+		repo = repository.NewDynamoDBRepository(config)
+	case "memory":
+		repo = repository.NewInMemoryRepository()
+	case "postgres":
+		var err error
+		repo, err = repository.NewPostgresRepository(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "sqlite":
+		var err error
+		repo, err = repository.NewSQLiteRepository(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown storage %q", config.Storage)
+	}
+
+	var mail ports.Mailer
+	switch config.Mailer {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(config)
+	case "log":
+		mail = mailer.NewLogMailer(config)
+	default:
+		log.Fatalf("unknown mailer %q", config.Mailer)
+	}
+
+	// Failed logins are counted by the repository unless asked otherwise
+	var attempts ports.LoginAttemptStore
+	switch config.LoginAttemptStore {
+	case "repository":
+	case "memory":
+		attempts = repository.NewInMemoryLoginAttemptStore()
+	default:
+		log.Fatalf("unknown login attempt store %q", config.LoginAttemptStore)
+	}
+
+	// Users log in with the identity providers that have a client id
+	providers, err := oauth.NewIdentityProviders(context.Background(), config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	svc := services.NewPortfolioService(&repo, mail, services.Options{
+		AppURL:               config.AppURL,
+		SecretKey:            []byte(config.SecretKey),
+		RequireVerifiedEmail: config.RequireVerified,
+		LoginAttempts:        attempts,
+		IdentityProviders:    providers,
+	})
+	// The first admin can't be granted the role by another admin. Anyone can
+	// sign up with the email, so it has to be verified first
+	if config.AdminEmail != "" {
+		admin, err := svc.ReadUserWithEmail(context.Background(), config.AdminEmail)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			log.Printf("admin %s has not signed up yet", config.AdminEmail)
+		case err != nil:
+			log.Fatalf("reading admin %s: %v", config.AdminEmail, err)
+		case !admin.EmailVerified:
+			log.Printf("admin %s has not verified their email yet", config.AdminEmail)
+		default:
+			if _, err := svc.SetUserRole(context.Background(), admin.Id, domain.RoleAdmin); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	keys, err := middleware.LoadKeyManager(*config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.JWTRotation > 0 {
+		go keys.RotateEvery(context.Background(), config.JWTRotation, config.JWTKeyOverlap)
+	}
+	gin.InitGinRoutes(*svc, keys, *config)
 }